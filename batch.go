@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// batchRow is a manifest row, VideoMeta with its files.
+type batchRow struct {
+	VideoMeta
+	Id        string `json:"id,omitempty"`
+	Video     string `json:"video,omitempty"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+	Priority  int    `json:"priority,omitempty"`
+}
+
+// batchResult is the outcome of a manifest row.
+type batchResult struct {
+	Row   int
+	Video string
+	Id    string
+	Err   error
+}
+
+// CSV columns holding lists, and their separators.
+var batchListColumns = map[string]string{
+	"tags":           ",",
+	"playlistIds":    ";",
+	"playlistTitles": ";",
+}
+
+// CSV columns holding integers.
+var batchIntColumns = map[string]bool{
+	"priority": true,
+}
+
+// CSV columns holding booleans.
+var batchBoolColumns = map[string]bool{
+	"embeddable":          true,
+	"publicStatsViewable": true,
+}
+
+// Resolve a manifest path relative to the manifest file.
+func batchPath(dir string, nam string) string {
+	if nam == "" || strings.HasPrefix(nam, "http") || filepath.IsAbs(nam) {
+		return nam
+	}
+	return filepath.Join(dir, nam)
+}
+
+// Convert a CSV record to its JSON object.
+func batchRecordJSON(head []string, rec []string) ([]byte, error) {
+	var obj = map[string]interface{}{}
+	var loc = map[string]interface{}{}
+	for i, k := range head {
+		if i >= len(rec) || rec[i] == "" {
+			continue
+		}
+		var v = rec[i]
+		if sep, ok := batchListColumns[k]; ok {
+			var arr []string
+			for _, itm := range strings.Split(v, sep) {
+				arr = append(arr, strings.TrimSpace(itm))
+			}
+			obj[k] = arr
+		} else if batchBoolColumns[k] {
+			obj[k] = parseBool(v, false)
+		} else if batchIntColumns[k] {
+			obj[k] = parseInt(v, 0)
+		} else if k == "latitude" || k == "longitude" {
+			loc[k] = parseFloat(v, 0)
+		} else {
+			obj[k] = v
+		}
+	}
+	if len(loc) > 0 {
+		obj["location"] = loc
+	}
+	return json.Marshal(obj)
+}
+
+// Read manifest rows as JSON objects, from JSONL or CSV.
+func readBatchManifest(nam string) ([][]byte, error) {
+	fil, err := os.Open(nam)
+	if err != nil {
+		return nil, err
+	}
+	defer fil.Close()
+	var ans [][]byte
+	if strings.EqualFold(filepath.Ext(nam), ".csv") {
+		r := csv.NewReader(fil)
+		r.FieldsPerRecord = -1
+		head, err := r.Read()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading manifest header: %v", err)
+		}
+		for i := range head {
+			head[i] = strings.TrimSpace(head[i])
+		}
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			dat, err := batchRecordJSON(head, rec)
+			if err != nil {
+				return nil, err
+			}
+			ans = append(ans, dat)
+		}
+		return ans, nil
+	}
+	s := bufio.NewScanner(fil)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		var l = strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
+			continue
+		}
+		ans = append(ans, []byte(l))
+	}
+	return ans, s.Err()
+}
+
+// Parse a manifest row into a video job.
+func parseBatchRow(dir string, dat []byte) (*videoJob, error) {
+	var r batchRow
+	if err := json.Unmarshal(dat, &r); err != nil {
+		return nil, err
+	}
+	m, err := ParseVideoMeta(dat)
+	if err != nil {
+		return nil, err
+	}
+	if r.Video == "" && r.Id == "" {
+		return nil, fmt.Errorf("Row has neither video nor id")
+	}
+	var captions = parseCaptions(r.Caption, "")
+	for i := range captions {
+		captions[i].File = batchPath(dir, captions[i].File)
+	}
+	for i := range m.Captions {
+		m.Captions[i].File = batchPath(dir, m.Captions[i].File)
+	}
+	return &videoJob{
+		Id:        r.Id,
+		Video:     batchPath(dir, r.Video),
+		Thumbnail: batchPath(dir, r.Thumbnail),
+		Captions:  captions,
+		Meta:      m,
+		Priority:  r.Priority,
+	}, nil
+}
+
+func printBatchResults(ans []batchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "ROW\tVIDEO\tID\tSTATUS\n")
+	for _, r := range ans {
+		var sta = "ok"
+		if r.Err != nil {
+			sta = r.Err.Error()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Row, shortString(r.Video, 40), r.Id, sta)
+	}
+	w.Flush()
+}
+
+// Upload all videos in a manifest, continuing past failed rows, with
+// parallel workers. Returns exit code of the first failed row, if any.
+func runBatch(api *apiClient, nam string) int {
+	rows, err := readBatchManifest(nam)
+	if err != nil {
+		printf("Error reading manifest '%s': %v\n", nam, err)
+		return exitError
+	}
+	var dir = filepath.Dir(nam)
+	var ans = make([]batchResult, len(rows))
+	var out = make([]*jobResult, len(rows))
+	runWorkers(api, len(rows), func(api *apiClient, i int) {
+		var r = batchResult{Row: i + 1}
+		var res = &jobResult{Event: "result"}
+		job, err := parseBatchRow(dir, rows[i])
+		if err == nil {
+			r.Video = parseString(job.Video, job.Id)
+			logf("[%d/%d] %s\n", i+1, len(rows), r.Video)
+			r.Id, err = api.runJob(job)
+			res = &job.Result
+		} else {
+			res.finish("", err)
+		}
+		res.Row = i + 1
+		if err != nil {
+			logf("[%d/%d] %v\n", i+1, len(rows), err)
+			r.Err = err
+		}
+		ans[i], out[i] = r, res
+		if f.Output == outputJSONL && !f.DryRun {
+			writeOutput(res)
+		}
+	})
+	var code = exitOK
+	for _, r := range ans {
+		if r.Err != nil && code == exitOK {
+			code = exitCode(r.Err)
+		}
+	}
+	if f.Output == outputJSON && !f.DryRun {
+		writeOutput(out)
+	} else if !outputIsJSON() {
+		printBatchResults(ans)
+	}
+	return code
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadBatchManifestCSV(t *testing.T) {
+	var dir = t.TempDir()
+	var pth = writeTestFile(t, dir, "shows.csv", []byte(
+		" video ,thumbnail,caption,title,tags,playlistTitles,latitude,longitude,embeddable,priority\n"+
+			"ep01.mp4,ep01.jpg,en:ep01.srt,Episode 1,\"show, pilot\",my show;best of,48.85,2.29,true,2\n"+
+			"/abs/ep02.mp4,,,Episode 2\n"))
+	rows, err := readBatchManifest(pth)
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("readBatchManifest = %d rows, %v", len(rows), err)
+	}
+	job, err := parseBatchRow(dir, rows[0])
+	if err != nil {
+		t.Fatalf("parseBatchRow(%s): %v", rows[0], err)
+	}
+	if job.Video != filepath.Join(dir, "ep01.mp4") || job.Thumbnail != filepath.Join(dir, "ep01.jpg") || job.Priority != 2 {
+		t.Errorf("video, thumbnail, priority = %s, %s, %d", job.Video, job.Thumbnail, job.Priority)
+	}
+	if len(job.Captions) != 1 || job.Captions[0].Language != "en" || job.Captions[0].File != filepath.Join(dir, "ep01.srt") {
+		t.Errorf("captions = %+v", job.Captions)
+	}
+	var m = job.Meta
+	if m.Title != "Episode 1" || !reflect.DeepEqual(m.Tags, []string{"show", "pilot"}) || !reflect.DeepEqual(m.PlaylistTitles, []string{"my show", "best of"}) {
+		t.Errorf("title, tags, playlists = %q, %q, %q", m.Title, m.Tags, m.PlaylistTitles)
+	}
+	if !m.Embeddable || m.Location == nil || m.Location.Latitude != 48.85 || m.Location.Longitude != 2.29 {
+		t.Errorf("embeddable, location = %v, %+v", m.Embeddable, m.Location)
+	}
+	// empty cells are left out
+	if job, err = parseBatchRow(dir, rows[1]); err != nil || job.Video != "/abs/ep02.mp4" || job.Thumbnail != "" || len(job.Meta.JSON) != 2 {
+		t.Errorf("row 2 = %+v, %v", job, err)
+	}
+}
+
+func TestReadBatchManifestJSONL(t *testing.T) {
+	var dir = t.TempDir()
+	var pth = writeTestFile(t, dir, "shows.jsonl", []byte(`# episodes
+{"video": "ep01.mp4", "title": "Episode 1", "captions": [{"language": "fr", "file": "fr.srt"}]}
+
+// existing video
+{"id": "xxxxxxxxxxx", "title": "Episode 2", "video": "https://example.com/ep02.mp4"}
+{"title": "no video"}
+`))
+	rows, err := readBatchManifest(pth)
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("readBatchManifest = %d rows, %v", len(rows), err)
+	}
+	job, err := parseBatchRow(dir, rows[0])
+	if err != nil || job.Video != filepath.Join(dir, "ep01.mp4") || job.Meta.Captions[0].File != filepath.Join(dir, "fr.srt") {
+		t.Errorf("row 1 = %+v, %v", job, err)
+	}
+	if job, err = parseBatchRow(dir, rows[1]); err != nil || job.Id != "xxxxxxxxxxx" || job.Video != "https://example.com/ep02.mp4" {
+		t.Errorf("row 2 = %+v, %v", job, err)
+	}
+	if _, err = parseBatchRow(dir, rows[2]); err == nil {
+		t.Errorf("row without video nor id should fail")
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	writeTestFile(t, dir, "ep01.mp4", []byte("video 1"))
+	writeTestFile(t, dir, "ep03.mp4", []byte("video 3"))
+	var pth = writeTestFile(t, dir, "shows.csv", []byte("video,title\nep01.mp4,Episode 1\nmissing.mp4,Episode 2\nep03.mp4,Episode 3\n"))
+	// failed rows don't stop others, first failure is exit code
+	if code := runBatch(api, pth); code != exitFile {
+		t.Errorf("runBatch = %d, want %d", code, exitFile)
+	}
+	var titles []string
+	for _, v := range fake.Videos() {
+		titles = append(titles, v.Snippet.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"Episode 1", "Episode 3"}) {
+		t.Errorf("uploaded %q, want Episode 1, 3", titles)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+// CaptionMeta is a caption track of a video.
+type CaptionMeta struct {
+	Language string `json:"language,omitempty"`
+	File     string `json:"file"`
+	Name     string `json:"name,omitempty"`
+	Draft    bool   `json:"draft,omitempty"`
+	// Sync replaces timings using speech recognition (true)
+	Sync *bool `json:"sync,omitempty"`
+}
+
+// Regexps
+var reLanguage = regexp.MustCompile("^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{1,8})*$")
+
+// Parse caption entries "lang:path[:name[:draft,nosync]]" separated by ";".
+// A plain path uses the default language.
+func parseCaptions(txt string, lng string) []CaptionMeta {
+	var ans []CaptionMeta
+	for _, e := range strings.Split(txt, ";") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		var c = CaptionMeta{Language: lng, File: e}
+		var p = strings.Split(e, ":")
+		if len(p) > 1 && reLanguage.MatchString(p[0]) {
+			c.Language = p[0]
+			p = p[1:]
+			// keep drive letter, or URL scheme with path
+			if len(p) > 1 && (len(p[0]) == 1 || strings.HasPrefix(p[0], "http")) {
+				p = append([]string{p[0] + ":" + p[1]}, p[2:]...)
+			}
+			c.File = p[0]
+			if len(p) > 1 {
+				c.Name = p[1]
+			}
+			if len(p) > 2 {
+				for _, o := range strings.Split(p[2], ",") {
+					switch strings.TrimSpace(o) {
+					case "draft":
+						c.Draft = true
+					case "sync":
+						c.Sync = &[]bool{true}[0]
+					case "nosync":
+						c.Sync = &[]bool{false}[0]
+					}
+				}
+			}
+		}
+		ans = append(ans, c)
+	}
+	return ans
+}
+
+// Get caption track of a caption.
+func captionTrack(c CaptionMeta, lng string) uploader.CaptionTrack {
+	var t = uploader.CaptionTrack{
+		Language: parseString(c.Language, lng),
+		Draft:    c.Draft,
+		Sync:     c.Sync == nil || *c.Sync,
+	}
+	t.Name = parseString(c.Name, t.Language)
+	return t
+}
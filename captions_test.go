@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCaptions(t *testing.T) {
+	var yes, no = true, false
+	var tests = []struct {
+		txt  string
+		want []CaptionMeta
+	}{
+		{"", nil},
+		{"en.srt", []CaptionMeta{{Language: "fr", File: "en.srt"}}},
+		{"en:en.srt; es:es.vtt:Español", []CaptionMeta{{Language: "en", File: "en.srt"}, {Language: "es", File: "es.vtt", Name: "Español"}}},
+		{"pt-BR:pt.srt:Português:draft,nosync", []CaptionMeta{{Language: "pt-BR", File: "pt.srt", Name: "Português", Draft: true, Sync: &no}}},
+		{"en:en.srt::sync", []CaptionMeta{{Language: "en", File: "en.srt", Sync: &yes}}},
+		{`en:C:\subs\en.srt:English`, []CaptionMeta{{Language: "en", File: `C:\subs\en.srt`, Name: "English"}}},
+		{`C:\subs\en.srt`, []CaptionMeta{{Language: "fr", File: `C:\subs\en.srt`}}},
+		{"de:https://example.com/de.srt:Deutsch", []CaptionMeta{{Language: "de", File: "https://example.com/de.srt", Name: "Deutsch"}}},
+		{"https://example.com/x.srt", []CaptionMeta{{Language: "fr", File: "https://example.com/x.srt"}}},
+	}
+	for _, tt := range tests {
+		if got := parseCaptions(tt.txt, "fr"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCaptions(%q) = %+v, want %+v", tt.txt, got, tt.want)
+		}
+	}
+}
+
+func TestCaptionTrack(t *testing.T) {
+	var no = false
+	var got = captionTrack(CaptionMeta{File: "a.srt"}, "en")
+	if got.Language != "en" || got.Name != "en" || !got.Sync || got.Draft {
+		t.Errorf("captionTrack of plain file = %+v", got)
+	}
+	got = captionTrack(CaptionMeta{Language: "es", Name: "Español", Draft: true, Sync: &no}, "en")
+	if got.Language != "es" || got.Name != "Español" || got.Sync || !got.Draft {
+		t.Errorf("captionTrack = %+v", got)
+	}
+}
+
+func TestRunJobCaptions(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var srt = writeTestFile(t, dir, "ep07.srt", []byte("1\n00:00:00,000 --> 00:00:01,000\nHi\n"))
+	var job = &videoJob{Video: writeTestFile(t, dir, "ep07.mp4", []byte("video")), Captions: parseCaptions("en:"+srt+";"+srt+";es:"+srt+":Español:draft", "en")}
+	id, err := api.runJob(job)
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	// same language and name is the same track
+	if c := fake.Captions(id); len(c) != 2 {
+		t.Fatalf("captions = %d tracks, want 2", len(c))
+	}
+	var actions []string
+	for _, r := range job.Result.Captions {
+		actions = append(actions, r.Language+":"+r.Name+":"+r.Action)
+	}
+	if want := []string{"en:en:uploaded", "en:en:updated", "es:Español:uploaded"}; !reflect.DeepEqual(actions, want) {
+		t.Errorf("caption results = %q, want %q", actions, want)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+// controlStatus is the state of uploads, as served by the control endpoint.
+type controlStatus struct {
+	Event string `json:"event"`
+	// upload rate in bytes/s (0 for no limit), set by control or schedule
+	Rate     int64            `json:"rate"`
+	Paused   bool             `json:"paused"`
+	Schedule bool             `json:"schedule"`
+	Uploads  []*progressEvent `json:"uploads"`
+	Canceled []string         `json:"canceled,omitempty"`
+}
+
+// Header of the token needed by TCP control requests. Browsers can't send
+// it to another site without asking first (it isn't a simple header).
+const controlTokenHeader = "X-Control-Token"
+
+// Check if a control address is a Unix socket path, or a TCP address.
+func controlUnix(addr string) bool {
+	return strings.Contains(addr, "/") || strings.HasSuffix(addr, ".sock")
+}
+
+// Check if a host name or address is of this machine only.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	var ip = net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func getControlTokenPath() string {
+	return credentialPath("client_control.token")
+}
+
+// Create a random control token file, readable by its owner only. Fails if
+// it exists.
+func newControlToken(pth string) (string, error) {
+	fil, err := os.OpenFile(pth, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	var dat = make([]byte, 32)
+	if _, err = rand.Read(dat); err == nil {
+		_, err = fil.WriteString(hex.EncodeToString(dat) + "\n")
+	}
+	if cerr := fil.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(pth)
+	}
+	return hex.EncodeToString(dat), err
+}
+
+// Get token of TCP control requests, shared by all runs, created if none.
+// It is refused if others than its owner can read it.
+func getControlToken(create bool) (string, error) {
+	var pth = getControlTokenPath()
+	if create {
+		if tok, err := newControlToken(pth); !os.IsExist(err) {
+			return tok, err
+		}
+	}
+	fi, err := os.Stat(pth)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("'%s' can be read by others, it should be 0600", pth)
+	}
+	dat, err := ioutil.ReadFile(pth)
+	return strings.TrimSpace(string(dat)), err
+}
+
+// Check a control request is allowed. Those over TCP need the token, a
+// loopback Host (against DNS rebinding), and no Origin of a web page.
+func checkControl(r *http.Request, token string) error {
+	if token == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if !isLoopback(host) {
+		return fmt.Errorf("Host '%s' is not loopback", r.Host)
+	}
+	if o := r.Header.Get("Origin"); o != "" && o != "http://"+r.Host {
+		return fmt.Errorf("Origin '%s' not allowed", o)
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(controlTokenHeader)), []byte(token)) != 1 {
+		return fmt.Errorf("Invalid or missing %s header", controlTokenHeader)
+	}
+	return nil
+}
+
+// Get status of uploads in progress.
+func getControlStatus() *controlStatus {
+	rate, ctl := getBandwidth().rate()
+	var ans = &controlStatus{Event: "status", Rate: rate, Schedule: !ctl, Uploads: []*progressEvent{}}
+	if rate == rateOff {
+		ans.Rate, ans.Paused = 0, true
+	}
+	for _, st := range uploadProgress.all() {
+		s := st.status
+		ans.Uploads = append(ans.Uploads, &progressEvent{
+			Event: "progress",
+			File:  st.nam,
+			Bytes: s.Bytes,
+			Total: st.filesize,
+			Rate:  s.CurRate,
+			ETA:   s.TimeRem.Round(time.Second).Seconds(),
+		})
+	}
+	return ans
+}
+
+// Serve control requests: GET /status, POST /rate (rate=2000kbps,
+// unlimited, off or schedule), /pause, /resume, /cancel (file=glob).
+func serveControl(w http.ResponseWriter, r *http.Request) {
+	var bw = getBandwidth()
+	var canceled []string
+	if r.URL.Path != "/status" && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch r.URL.Path {
+	case "/status":
+	case "/rate":
+		var txt = r.FormValue("rate")
+		if strings.ToLower(txt) == "schedule" {
+			bw.setRate(0, true)
+			break
+		}
+		rate, err := parseLimitRate(txt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bw.setRate(rate, false)
+	case "/pause":
+		bw.hold(true)
+	case "/resume":
+		bw.hold(false)
+	case "/cancel":
+		canceled = uploadProgress.cancel(r.FormValue("file"))
+		for _, nam := range canceled {
+			logf("Canceling upload of '%s'...\n", nam)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	var ans = getControlStatus()
+	ans.Canceled = canceled
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ans)
+}
+
+// Listen for control requests, on a Unix socket only its owner can use, or
+// a loopback TCP address with token (none for sockets).
+func listenControl(addr string) (net.Listener, string, error) {
+	if !controlUnix(addr) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, "", err
+		}
+		if !isLoopback(host) {
+			return nil, "", fmt.Errorf("'%s' is not a loopback address ex- \"localhost:8091\"", addr)
+		}
+		token, err := getControlToken(true)
+		if err != nil {
+			return nil, "", err
+		}
+		ln, err := net.Listen("tcp", addr)
+		return ln, token, err
+	}
+	// remove socket left by a previous run, not one of a running one
+	if fi, err := os.Stat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.DialTimeout("unix", addr, time.Second); err == nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("'%s' is in use by another run", addr)
+		}
+		os.Remove(addr)
+	}
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = os.Chmod(addr, 0600); err != nil {
+		ln.Close()
+		return nil, "", err
+	}
+	return ln, "", nil
+}
+
+// Serve control endpoint if set, on a Unix socket or a TCP address.
+func startControl() {
+	var addr = f.ControlAddr
+	if addr == "" || f.DryRun || validateOnly {
+		return
+	}
+	ln, token, err := listenControl(addr)
+	if err != nil {
+		printf("Error starting control endpoint: %v\n", err)
+		os.Exit(exitError)
+	}
+	logf("Control endpoint listening on %s\n", addr)
+	go http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkControl(r, token); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		serveControl(w, r)
+	}))
+}
+
+// Get HTTP client and base URL of a control endpoint.
+func controlClient(addr string) (*http.Client, string) {
+	if !controlUnix(addr) {
+		return &http.Client{Timeout: 10 * time.Second}, "http://" + addr
+	}
+	var dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", addr)
+	}
+	return &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{DialContext: dial}}, "http://control"
+}
+
+// Send a control request, and get status of uploads.
+func sendControl(addr string, pth string, form url.Values) (*controlStatus, error) {
+	cli, base := controlClient(addr)
+	var req *http.Request
+	var err error
+	if form == nil {
+		req, err = http.NewRequest(http.MethodGet, base+pth, nil)
+	} else {
+		req, err = http.NewRequest(http.MethodPost, base+pth, strings.NewReader(form.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !controlUnix(addr) {
+		token, err := getControlToken(false)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(controlTokenHeader, token)
+	}
+	res, err := cli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		dat, _ := ioutil.ReadAll(res.Body)
+		return nil, errors.New(strings.TrimSpace(string(dat)))
+	}
+	var ans = &controlStatus{}
+	return ans, json.NewDecoder(res.Body).Decode(ans)
+}
+
+// Write status of uploads as text.
+func writeControlStatus(st *controlStatus) {
+	var rate = "no limit"
+	if st.Rate > 0 {
+		rate = strings.TrimSpace(formatRate(st.Rate))
+	}
+	if st.Paused {
+		rate = "paused"
+	}
+	var by = "control"
+	if st.Schedule {
+		by = "schedule"
+	}
+	for _, nam := range st.Canceled {
+		printf("Canceled '%s'\n", nam)
+	}
+	printf("Upload rate: %s (%s)\n", rate, by)
+	if len(st.Uploads) == 0 {
+		printf("No uploads in progress\n")
+		return
+	}
+	var w = tabwriter.NewWriter(textOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "FILE\tBYTES\tTOTAL\tRATE\tETA\n")
+	for _, u := range st.Uploads {
+		var eta = time.Duration(u.ETA) * time.Second
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%v\n", u.File, u.Bytes, u.Total, strings.TrimSpace(formatRate(u.Rate)), eta)
+	}
+	w.Flush()
+}
+
+// Control a running upload, as "ctl status|rate <rate>|pause|resume|cancel [file]".
+func onCtl(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	getFlags()
+	uploader.Logf = logf
+	if f.ControlAddr == "" {
+		printf("No control endpoint address (-ca)!\n")
+		os.Exit(exitError)
+	}
+	var pth, form = "/" + parseString(flag.Arg(0), "status"), url.Values{}
+	switch pth {
+	case "/status":
+		form = nil
+	case "/rate":
+		if flag.Arg(1) == "" {
+			printf("No upload rate ex- \"2000kbps\", \"unlimited\", \"off\" or \"schedule\"!\n")
+			os.Exit(exitError)
+		}
+		form.Set("rate", flag.Arg(1))
+	case "/cancel":
+		form.Set("file", flag.Arg(1))
+	case "/pause", "/resume":
+	default:
+		printf("Unknown control command '%s' (status, rate, pause, resume, cancel)\n", flag.Arg(0))
+		os.Exit(exitError)
+	}
+	st, err := sendControl(f.ControlAddr, pth, form)
+	if err != nil {
+		printf("Error controlling upload at '%s': %v\n", f.ControlAddr, err)
+		os.Exit(exitError)
+	}
+	if outputIsJSON() {
+		writeOutput(st)
+		return
+	}
+	writeControlStatus(st)
+}
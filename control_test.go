@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenControl(t *testing.T) {
+	credentialDir = t.TempDir()
+	for _, addr := range []string{":0", "0.0.0.0:0", "192.0.2.1:8091", "example.com:8091"} {
+		if ln, _, err := listenControl(addr); err == nil {
+			ln.Close()
+			t.Errorf("listenControl(%q) should refuse a non-loopback address", addr)
+		}
+	}
+	ln, token, err := listenControl("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listenControl: %v", err)
+	}
+	ln.Close()
+	fi, err := os.Stat(getControlTokenPath())
+	if err != nil || fi.Mode().Perm() != 0600 || len(token) != 64 {
+		t.Fatalf("token file %v, %v, token %q", fi, err, token)
+	}
+	// token is kept, and refused if others can read it
+	if got, err := getControlToken(true); err != nil || got != token {
+		t.Errorf("getControlToken = %q, %v, want %q", got, err, token)
+	}
+	os.Chmod(getControlTokenPath(), 0644)
+	if _, err = getControlToken(false); err == nil {
+		t.Errorf("getControlToken should refuse a token file readable by others")
+	}
+}
+
+func TestListenControlSocket(t *testing.T) {
+	var pth = filepath.Join(t.TempDir(), "ctl.sock")
+	ln, token, err := listenControl(pth)
+	if err != nil || token != "" {
+		t.Fatalf("listenControl = %q, %v", token, err)
+	}
+	// a socket of a running instance is kept
+	if ln2, _, err := listenControl(pth); err == nil {
+		ln2.Close()
+		t.Errorf("listenControl should refuse a socket in use")
+	}
+	// a socket left by a previous run is replaced
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if ln, _, err = listenControl(pth); err != nil {
+		t.Fatalf("listenControl of stale socket: %v", err)
+	}
+	ln.Close()
+}
+
+func TestCheckControl(t *testing.T) {
+	var tests = []struct {
+		host   string
+		origin string
+		token  string
+		ok     bool
+	}{
+		{"localhost:8091", "", "secret", true},
+		{"127.0.0.1:8091", "http://127.0.0.1:8091", "secret", true},
+		{"[::1]:8091", "", "secret", true},
+		{"localhost:8091", "", "", false},
+		{"localhost:8091", "", "wrong", false},
+		{"evil.example:8091", "", "secret", false},
+		{"localhost:8091", "http://evil.example", "secret", false},
+	}
+	for _, tt := range tests {
+		var r = httptest.NewRequest(http.MethodPost, "http://"+tt.host+"/pause", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.token != "" {
+			r.Header.Set(controlTokenHeader, tt.token)
+		}
+		if err := checkControl(r, "secret"); (err == nil) != tt.ok {
+			t.Errorf("checkControl(%s, %q, %q) = %v, want ok %v", tt.host, tt.origin, tt.token, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+)
+
+// credential is a client id and token pair.
+type credential struct {
+	ID    string
+	Token string
+}
+
+// apiClient is the API client of the current credential.
+type apiClient struct {
+	ctx       context.Context
+	http      *http.Client
+	service   *youtube.Service
+	transport *limitTransport
+	quota     *uploader.QuotaTransport
+	ledger    *uploader.QuotaLedger
+	tried     map[string]bool
+	// client id of current credential
+	clientID string
+}
+
+// Global variables
+var credentials []credential
+
+// Directory of the first configured client token, before shuffling.
+var credentialDir string
+
+// Quota ledger shared by all API clients, loaded on first use.
+var quotaLedger *uploader.QuotaLedger
+var quotaLedgerMu sync.Mutex
+
+// Connecting sets credential flags, one API client at a time.
+var connectMu sync.Mutex
+
+// Get client id and token pairs, in random order.
+func getCredentials(ids string, tokens string) []credential {
+	var ai = strings.Split(ids, ";")
+	var at = strings.Split(tokens, ";")
+	var n = len(ai)
+	if len(at) > n {
+		n = len(at)
+	}
+	var ans []credential
+	for i := 0; i < n; i++ {
+		ans = append(ans, credential{ai[i%len(ai)], at[i%len(at)]})
+	}
+	rand.Shuffle(len(ans), func(i, j int) { ans[i], ans[j] = ans[j], ans[i] })
+	return ans
+}
+
+func getQuotaLimit() int64 {
+	return int64(parseInt(f.QuotaLimit, uploader.DefaultQuota))
+}
+
+// Get path of a file kept next to the first configured client token, the
+// same whichever credential is used.
+func credentialPath(nam string) string {
+	return filepath.Join(credentialDir, nam)
+}
+
+func getQuotaLedgerPath() string {
+	return parseString(f.QuotaLedger, credentialPath("client_quota.json"))
+}
+
+// Select credential with most remaining quota, skipping tried ones.
+func selectCredential(l *uploader.QuotaLedger, limit int64, tried map[string]bool) (credential, bool) {
+	var ans credential
+	var max int64 = -1
+	for _, c := range credentials {
+		var rem = limit - l.Used(c.ID)
+		if tried[c.ID] || rem <= max {
+			continue
+		}
+		ans, max = c, rem
+	}
+	return ans, max > 0
+}
+
+// Get quota ledger, shared by all API clients.
+func getQuotaLedger() (*uploader.QuotaLedger, error) {
+	quotaLedgerMu.Lock()
+	defer quotaLedgerMu.Unlock()
+	if quotaLedger != nil {
+		return quotaLedger, nil
+	}
+	l, err := uploader.LoadQuotaLedger(getQuotaLedgerPath())
+	if err != nil {
+		return nil, err
+	}
+	quotaLedger = l
+	return l, nil
+}
+
+// Connect to API with a credential.
+func (a *apiClient) connect(c credential) {
+	connectMu.Lock()
+	defer connectMu.Unlock()
+	a.clientID = c.ID
+	f.ClientID = c.ID
+	f.ClientToken = c.Token
+	a.tried[c.ID] = true
+	a.quota.SetKey(c.ID)
+	client, err := buildOAuthHTTPClient(a.ctx, []string{youtube.YoutubeUploadScope, youtube.YoutubepartnerScope, youtube.YoutubeScope})
+	if err != nil {
+		log.Fatalf("Error building OAuth client: %v", err)
+	}
+	service, err := youtube.New(client)
+	if err != nil {
+		log.Fatalf("Error creating YouTube client: %s", err)
+	}
+	if f.ApiEndpoint != "" {
+		service.BasePath = endpointURL("")
+	}
+	a.http = client
+	a.service = service
+}
+
+// Switch to the credential with most remaining quota, if any.
+func (a *apiClient) failover() bool {
+	if err := a.ledger.Exhaust(a.clientID, getQuotaLimit()); err != nil {
+		logf("Error saving quota ledger: %v\n", err)
+	}
+	c, ok := selectCredential(a.ledger, getQuotaLimit(), a.tried)
+	if !ok {
+		return false
+	}
+	logf("Quota exceeded, switching to client id '%s'...\n", c.ID)
+	a.connect(c)
+	return true
+}
+
+// Create API client, with the credential having most remaining quota.
+func newAPIClient(ctx context.Context, transport *limitTransport) *apiClient {
+	ledger, err := getQuotaLedger()
+	if err != nil {
+		log.Fatalf("Error loading quota ledger: %v", err)
+	}
+	quota := &uploader.QuotaTransport{Base: transport, Ledger: ledger}
+	retry := &uploader.RetryTransport{
+		Base:       quota,
+		MaxRetries: parseInt(f.MaxRetries, 8),
+		Budget:     parseDuration(f.RetryBudget, 0),
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
+		Transport: retry,
+	})
+	a := &apiClient{ctx: ctx, transport: transport, quota: quota, ledger: ledger, tried: map[string]bool{}}
+	c, _ := selectCredential(ledger, getQuotaLimit(), nil)
+	if c.ID == "" {
+		c = credentials[0]
+	}
+	a.connect(c)
+	return a
+}
+
+// Run a job, switching credential when quota is exceeded. An uploaded video
+// is updated instead of uploaded again; thumbnail, captions and playlists
+// are applied again (replacing tracks, skipping playlists having it). A nil
+// client (dry run) runs once.
+func (a *apiClient) runJob(job *videoJob) (string, error) {
+	for {
+		id, err := runJob(a, job)
+		if a == nil || !errors.Is(err, uploader.ErrQuotaExceeded) || !a.failover() {
+			job.Result.finish(id, err)
+			return id, err
+		}
+		if id != "" {
+			job.Id, job.Existing = id, true
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+func TestSelectCredential(t *testing.T) {
+	credentials = []credential{{"a", "ta"}, {"b", "tb"}, {"c", "tc"}}
+	l, err := uploader.LoadQuotaLedger(filepath.Join(t.TempDir(), "quota.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 9000)
+	l.Add("b", 2000)
+	l.Add("c", 5000)
+	var tests = []struct {
+		tried map[string]bool
+		want  string
+		ok    bool
+	}{
+		{nil, "b", true},
+		{map[string]bool{"b": true}, "c", true},
+		{map[string]bool{"b": true, "c": true}, "a", true},
+		{map[string]bool{"a": true, "b": true, "c": true}, "", false},
+	}
+	for _, tt := range tests {
+		if c, ok := selectCredential(l, 10000, tt.tried); c.ID != tt.want || ok != tt.ok {
+			t.Errorf("selectCredential(%v) = %q, %v, want %q, %v", tt.tried, c.ID, ok, tt.want, tt.ok)
+		}
+	}
+	// none with quota left
+	for _, c := range credentials {
+		l.Exhaust(c.ID, 10000)
+	}
+	if c, ok := selectCredential(l, 10000, nil); ok {
+		t.Errorf("selectCredential = %q, want none with quota", c.ID)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	fake, _, dir := newTestAPI(t)
+	var id2 = writeTestFile(t, dir, "client_id2.json", []byte(`{"installed":{"client_id":"x2","client_secret":"y","redirect_uris":["http://localhost"]}}`))
+	var token2 = writeTestFile(t, dir, "client_token2.json", []byte(`{"access_token":"b","token_type":"Bearer","refresh_token":"r","expiry":"2099-01-01T00:00:00Z"}`))
+	var id1 = f.ClientID
+	f.ClientID += ";" + id2
+	f.ClientToken += ";" + token2
+	getFlagsDynamic()
+	quotaLedger = nil
+	// first credential selected, as second has less quota left
+	l, _ := getQuotaLedger()
+	l.Add(id2, 5000)
+	var api = getAPIClient()
+	if api.clientID != id1 {
+		t.Fatalf("selected %s, want %s", api.clientID, id1)
+	}
+	fake.ExhaustQuota("a")
+	var job = &videoJob{Video: writeTestFile(t, dir, "ep04.mp4", []byte("video"))}
+	f.Title = "ep04"
+	id, err := api.runJob(job)
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	if api.clientID != id2 || len(fake.Videos()) != 1 || id == "" {
+		t.Errorf("credential, videos = %s, %d, want %s, 1", api.clientID, len(fake.Videos()), id2)
+	}
+	if l.Used(id1) != getQuotaLimit() {
+		t.Errorf("used by exhausted credential = %d, want %d", l.Used(id1), getQuotaLimit())
+	}
+	// no credential left, dry run fails without failover
+	fake.ExhaustQuota("b")
+	f.DryRun = true
+	readAPI = nil
+	var none *apiClient
+	if _, err = none.runJob(&videoJob{Id: id}); !errors.Is(err, uploader.ErrQuotaExceeded) {
+		t.Errorf("dry run = %v, want quota exceeded", err)
+	}
+}
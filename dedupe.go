@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+// Ledger of uploaded videos by content hash, loaded on first use.
+var uploadLedger *uploader.UploadLedger
+var uploadLedgerMu sync.Mutex
+
+func getUploadLedgerPath() string {
+	return parseString(f.UploadLedger, credentialPath("client_uploads.json"))
+}
+
+func getUploadLedger() (*uploader.UploadLedger, error) {
+	uploadLedgerMu.Lock()
+	defer uploadLedgerMu.Unlock()
+	if uploadLedger != nil {
+		return uploadLedger, nil
+	}
+	l, err := uploader.LoadUploadLedger(getUploadLedgerPath())
+	if err != nil {
+		return nil, err
+	}
+	uploadLedger = l
+	return l, nil
+}
+
+// Locks of content hashes, held by the job uploading one, so that identical
+// files of a parallel batch are uploaded once (others find it in ledger).
+var uploadLocks = map[string]*sync.Mutex{}
+var uploadLocksMu sync.Mutex
+
+// Lock a content hash, and return its unlock.
+func lockUpload(hash string) func() {
+	uploadLocksMu.Lock()
+	var mu = uploadLocks[hash]
+	if mu == nil {
+		mu = &sync.Mutex{}
+		uploadLocks[hash] = mu
+	}
+	uploadLocksMu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Get content hash of a video file, none for URLs.
+func videoHash(nam string) (string, error) {
+	if nam == "" || strings.HasPrefix(nam, "http") {
+		return "", nil
+	}
+	logf("Hashing file '%s'...\n", nam)
+	return uploader.HashFile(nam, int64(parseInt(f.HashSize, 0))*1024*1024)
+}
+
+// Get video tag having a content hash, as "sha256-<first 20 hex digits>".
+func hashTag(hash string) string {
+	var i = strings.Index(hash, ":")
+	return hash[:i] + "-" + hash[i+1:i+21]
+}
+
+// Find video uploaded before with same content, in ledger or by hash tag
+// (with --hash_tag). Ledger entries of deleted videos are dropped. Dry run
+// checks the ledger only, sending no request.
+func findUpload(api *apiClient, hash string, nam string) (string, error) {
+	l, err := getUploadLedger()
+	if err != nil {
+		return "", err
+	}
+	if id, ok := l.Get(hash); ok {
+		if f.DryRun {
+			return id, nil
+		}
+		_, err := uploader.GetVideo(readAPIClient(api).service, id, []string{"id"})
+		if !errors.Is(err, uploader.ErrVideoNotFound) {
+			return id, err
+		}
+		logf("Video %v of '%s' no longer exists\n", id, nam)
+		return "", l.Remove(hash)
+	}
+	if !f.HashTag || f.DryRun {
+		return "", nil
+	}
+	logf("Searching uploads with tag '%s'...\n", hashTag(hash))
+	ids, err := uploader.FindVideoTag(readAPIClient(api).service, hashTag(hash))
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], l.Add(hash, ids[0], nam)
+}
+
+// Record a video uploaded with a content hash.
+func addUpload(hash string, id string, nam string) {
+	if hash == "" {
+		return
+	}
+	l, err := getUploadLedger()
+	if err == nil {
+		err = l.Add(hash, id, nam)
+	}
+	if err != nil {
+		printf("Error saving upload ledger: %v\n", err)
+	}
+}
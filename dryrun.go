@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Placeholder id of a video not uploaded yet.
+const dryRunVideoID = "<new video id>"
+
+// dryRequest is an API request, which is printed instead of sent.
+type dryRequest struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Parts  []string          `json:"parts,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	Body   interface{}       `json:"body,omitempty"`
+	Media  string            `json:"media,omitempty"`
+	Note   string            `json:"note,omitempty"`
+	Cost   int64             `json:"cost"`
+	// Optional requests depend on the current state of the channel.
+	Optional bool `json:"optional,omitempty"`
+}
+
+func newDryRequest(method string, pth string, parts []string, body interface{}) *dryRequest {
+	return &dryRequest{Method: method, Path: pth, Parts: parts, Body: body, Cost: uploader.QuotaCosts[method+" "+pth]}
+}
+
+// Get requests to add a video to a playlist, by id or title.
+func dryRunPlaylist(pid string, title string, sta string, id string) []*dryRequest {
+	var ans = []*dryRequest{newDryRequest("GET", "playlists", []string{"snippet", "contentDetails"}, nil)}
+	ans[0].Params = map[string]string{"mine": "true"}
+	if title != "" {
+		pid = "<id of playlist '" + title + "'>"
+		r := newDryRequest("POST", "playlists", []string{"snippet", "status"}, &youtube.Playlist{
+			Snippet: &youtube.PlaylistSnippet{Title: title},
+			Status:  &youtube.PlaylistStatus{PrivacyStatus: sta},
+		})
+		r.Note = "only if playlist doesn't exist"
+		r.Optional = true
+		ans = append(ans, r)
+	}
+	r := newDryRequest("GET", "playlistItems", []string{"id"}, nil)
+	r.Params = map[string]string{"playlistId": pid, "videoId": id}
+	ans = append(ans, r)
+	r = newDryRequest("POST", "playlistItems", []string{"snippet"}, &youtube.PlaylistItem{
+		Snippet: &youtube.PlaylistItemSnippet{
+			PlaylistId: pid,
+			ResourceId: &youtube.ResourceId{VideoId: id, Kind: "youtube#video"},
+		},
+	})
+	r.Note = "only if video isn't in playlist"
+	r.Optional = true
+	return append(ans, r)
+}
+
+// Get requests a job would send, in order.
+func dryRunRequests(job *videoJob, upload *youtube.Video, m *VideoMeta, captions []CaptionMeta, siz int64) []*dryRequest {
+	var ans []*dryRequest
+	var id = job.Id
+	if job.Video != "" && !job.Existing {
+		r := newDryRequest("POST", "videos", uploader.VideoParts(upload), upload)
+		r.Media = job.Video
+		if siz > 0 {
+			r.Params = map[string]string{"uploadType": "resumable"}
+			r.Media += " (" + strconv.FormatInt(siz, 10) + " bytes)"
+		}
+		ans = append(ans, r)
+		id = dryRunVideoID
+	} else if id != "" {
+		r := newDryRequest("GET", "videos", updateParts, nil)
+		r.Params = map[string]string{"id": id}
+		r.Note = "sent, to merge given fields into the current video"
+		if job.Existing {
+			r.Note = "video uploaded before (in upload ledger), given fields are merged into it"
+		}
+		ans = append(ans, r)
+		if parts := changedParts(job.Result.Changes); len(parts) > 0 {
+			r = newDryRequest("PUT", "videos", parts, upload)
+			r.Note = "after GET videos, to check the video has not changed"
+			ans = append(ans, r)
+		}
+	}
+	if id == "" {
+		return ans
+	}
+	if job.Thumbnail != "" {
+		r := newDryRequest("POST", "thumbnails/set", nil, nil)
+		r.Params = map[string]string{"videoId": id}
+		r.Media = job.Thumbnail
+		ans = append(ans, r)
+	}
+	for _, c := range captions {
+		var t = captionTrack(c, upload.Snippet.DefaultLanguage)
+		r := newDryRequest("GET", "captions", []string{"snippet"}, nil)
+		r.Params = map[string]string{"videoId": id}
+		ans = append(ans, r)
+		r = newDryRequest("POST", "captions", []string{"snippet"}, &youtube.Caption{
+			Snippet: &youtube.CaptionSnippet{VideoId: id, Language: t.Language, Name: t.Name, IsDraft: t.Draft},
+		})
+		r.Params = map[string]string{"sync": strconv.FormatBool(t.Sync)}
+		r.Media = c.File
+		r.Note = "PUT captions instead, if track with same language and name exists"
+		ans = append(ans, r)
+	}
+	var sta = upload.Status.PrivacyStatus
+	if m.PlaylistID != "" {
+		ans = append(ans, dryRunPlaylist(m.PlaylistID, "", sta, id)...)
+	}
+	for _, pid := range m.PlaylistIDs {
+		ans = append(ans, dryRunPlaylist(pid, "", sta, id)...)
+	}
+	for _, title := range m.PlaylistTitles {
+		ans = append(ans, dryRunPlaylist("", title, sta, id)...)
+	}
+	return ans
+}
+
+// dryRun is the requests of a job, with their estimated quota cost.
+type dryRun struct {
+	Event    string        `json:"event"`
+	Requests []*dryRequest `json:"requests"`
+	Cost     int64         `json:"cost"`
+	MaxCost  int64         `json:"maxCost"`
+}
+
+// Print requests, with their estimated quota cost.
+func printDryRun(reqs []*dryRequest) error {
+	var cost, max int64
+	for _, r := range reqs {
+		if !r.Optional {
+			cost += r.Cost
+		}
+		max += r.Cost
+	}
+	if outputIsJSON() {
+		writeOutput(&dryRun{Event: "dryRun", Requests: reqs, Cost: cost, MaxCost: max})
+		return nil
+	}
+	var enc = json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	for _, r := range reqs {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if max > cost {
+		fmt.Printf("Estimated quota cost: %d units (up to %d)\n", cost, max)
+	} else {
+		fmt.Printf("Estimated quota cost: %d units\n", cost)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Run a job in dry run, getting requests printed as JSON.
+func dryRunJob(t *testing.T, job *videoJob) *dryRun {
+	f.DryRun = true
+	f.Output = outputJSON
+	var ans = &dryRun{}
+	var out = captureStdout(t, func() {
+		if _, err := runJob(nil, job); err != nil {
+			t.Errorf("dry run: %v", err)
+		}
+	})
+	if err := json.Unmarshal([]byte(out), ans); err != nil {
+		t.Fatalf("dry run output %q: %v", out, err)
+	}
+	return ans
+}
+
+func dryRunCalls(d *dryRun) string {
+	var ans []string
+	for _, r := range d.Requests {
+		var s = r.Method + " " + r.Path
+		if r.Optional {
+			s += "?"
+		}
+		ans = append(ans, s)
+	}
+	return strings.Join(ans, ", ")
+}
+
+func TestDryRunUpload(t *testing.T) {
+	fake, _, dir := newTestAPI(t)
+	var job = &videoJob{
+		Video:     writeTestFile(t, dir, "ep05.mp4", []byte("video")),
+		Thumbnail: writeTestFile(t, dir, "ep05.jpg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00\xff\xd9")),
+		Captions:  []CaptionMeta{{Language: "en", File: writeTestFile(t, dir, "ep05.srt", []byte("1\n00:00:00,000 --> 00:00:01,000\nHi\n"))}},
+	}
+	job.Meta.PlaylistTitles = []string{"Episodes"}
+	f.Title = "Episode 5"
+	var d = dryRunJob(t, job)
+	var want = "POST videos, POST thumbnails/set, GET captions, POST captions, GET playlists, POST playlists?, GET playlistItems, POST playlistItems?"
+	if got := dryRunCalls(d); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+	// optional requests only count in maximum
+	if d.Cost != 2102 || d.MaxCost != 2202 {
+		t.Errorf("cost = %d, up to %d, want 2102, up to 2202", d.Cost, d.MaxCost)
+	}
+	if r := d.Requests[3]; r.Params["sync"] != "true" || r.Media != job.Captions[0].File {
+		t.Errorf("caption request = %+v", r)
+	}
+	if len(fake.Videos()) != 0 || len(fake.Playlists()) != 0 {
+		t.Errorf("dry run changed channel")
+	}
+}
+
+func TestDryRunUpdate(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	f.Title = "old"
+	id, err := api.runJob(&videoJob{Video: writeTestFile(t, dir, "ep06.mp4", []byte("video"))})
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	// current video is fetched, only changed parts are sent
+	f.Title = "new"
+	var d = dryRunJob(t, &videoJob{Id: id})
+	if got, want := dryRunCalls(d), "GET videos, PUT videos"; got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+	if got := d.Requests[1].Parts; len(got) != 1 || got[0] != "snippet" {
+		t.Errorf("updated parts = %v, want [snippet]", got)
+	}
+	if d.Cost != 51 || d.MaxCost != 51 {
+		t.Errorf("cost = %d, up to %d, want 51", d.Cost, d.MaxCost)
+	}
+	if v, _ := fake.Video(id); v.Snippet.Title != "old" {
+		t.Errorf("dry run updated title to %q", v.Snippet.Title)
+	}
+	// nothing changed, nothing to send
+	f.Title = "old"
+	if got := dryRunCalls(dryRunJob(t, &videoJob{Id: id})); got != "GET videos" {
+		t.Errorf("requests = %s, want GET videos", got)
+	}
+}
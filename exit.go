@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/googleapi"
+)
+
+// Exit codes
+const (
+	exitOK               = 0
+	exitError            = 1
+	exitFile             = 2
+	exitQuotaExceeded    = 3
+	exitAuthExpired      = 4
+	exitVideoNotFound    = 5
+	exitPlaylistNotFound = 6
+	exitSessionExpired   = 7
+	exitAPIError         = 8
+	exitInvalidMeta      = 9
+	exitVideoChanged     = 10
+	exitCanceled         = 11
+)
+
+// Get exit code for an error.
+func exitCode(err error) int {
+	var ae *googleapi.Error
+	var ve *validationError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ve):
+		return exitInvalidMeta
+	case errors.Is(err, uploader.ErrFile):
+		return exitFile
+	case errors.Is(err, uploader.ErrQuotaExceeded):
+		return exitQuotaExceeded
+	case errors.Is(err, uploader.ErrAuthExpired):
+		return exitAuthExpired
+	case errors.Is(err, uploader.ErrVideoNotFound):
+		return exitVideoNotFound
+	case errors.Is(err, uploader.ErrPlaylistNotFound):
+		return exitPlaylistNotFound
+	case errors.Is(err, uploader.ErrSessionExpired):
+		return exitSessionExpired
+	case errors.Is(err, uploader.ErrVideoChanged):
+		return exitVideoChanged
+	case errors.Is(err, uploader.ErrCanceled):
+		return exitCanceled
+	case errors.As(err, &ae):
+		return exitAPIError
+	}
+	return exitError
+}
+
+// Log error and exit with its exit code.
+func fatal(err error) {
+	log.Print(err)
+	os.Exit(exitCode(err))
+}
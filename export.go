@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Get thumbnail URLs, by size.
+func thumbnailURLs(t *youtube.ThumbnailDetails) map[string]string {
+	var ans = map[string]string{}
+	for k, v := range map[string]*youtube.Thumbnail{
+		"default": t.Default, "medium": t.Medium, "high": t.High, "standard": t.Standard, "maxres": t.Maxres,
+	} {
+		if v != nil {
+			ans[k] = v.Url
+		}
+	}
+	return ans
+}
+
+// Get META fields of a video.
+func videoMeta(y *youtube.Video) VideoMeta {
+	var m = VideoMeta{Localizations: y.Localizations}
+	if s := y.Snippet; s != nil {
+		m.Title = s.Title
+		m.Description = s.Description
+		m.CategoryId = s.CategoryId
+		m.Tags = s.Tags
+		m.Language = s.DefaultLanguage
+		if s.DefaultAudioLanguage != s.DefaultLanguage {
+			m.AudioLanguage = s.DefaultAudioLanguage
+		}
+		if s.Thumbnails != nil {
+			m.Thumbnails = thumbnailURLs(s.Thumbnails)
+		}
+	}
+	if st := y.Status; st != nil {
+		m.PrivacyStatus = st.PrivacyStatus
+		m.Embeddable = st.Embeddable
+		m.License = st.License
+		m.PublicStatsViewable = st.PublicStatsViewable
+		m.PublishAt = st.PublishAt
+	}
+	if rd := y.RecordingDetails; rd != nil {
+		m.Location = rd.Location
+		m.LocationDescription = rd.LocationDescription
+		if t, err := time.Parse(time.RFC3339, rd.RecordingDate); err == nil {
+			m.RecordingDate = &Date{t}
+		}
+	}
+	return m
+}
+
+// Get META of a video, with its caption tracks and playlists.
+func exportVideoMeta(srv *youtube.Service, id string) (VideoMeta, error) {
+	y, err := uploader.GetVideo(srv, id, updateParts)
+	if err != nil {
+		return VideoMeta{}, err
+	}
+	var m = videoMeta(y)
+	captions, err := uploader.ListCaptions(srv, id)
+	if err != nil {
+		return m, err
+	}
+	for _, c := range captions {
+		// automatic captions can't be uploaded
+		if c.Snippet.TrackKind == "asr" {
+			continue
+		}
+		m.Captions = append(m.Captions, CaptionMeta{Language: c.Snippet.Language, Name: c.Snippet.Name, Draft: c.Snippet.IsDraft})
+	}
+	m.PlaylistIDs, err = uploader.VideoPlaylists(srv, id)
+	return m, err
+}
+
+// Get requests to export a video.
+func dryRunExport(id string) []*dryRequest {
+	v := newDryRequest("GET", "videos", updateParts, nil)
+	v.Params = map[string]string{"id": id}
+	c := newDryRequest("GET", "captions", []string{"snippet"}, nil)
+	c.Params = map[string]string{"videoId": id}
+	p := newDryRequest("GET", "playlists", []string{"snippet"}, nil)
+	p.Params = map[string]string{"mine": "true", "maxResults": "50"}
+	i := newDryRequest("GET", "playlistItems", []string{"id"}, nil)
+	i.Params = map[string]string{"playlistId": "<each playlist id>", "videoId": id}
+	i.Note = "once per playlist"
+	return []*dryRequest{v, c, p, i}
+}
+
+// Get export arguments, with "-o <file>" (not an output format) as -of.
+func exportArgs(args []string) []string {
+	var ans = append([]string{}, args...)
+	for i, a := range ans {
+		var kv = strings.SplitN(a, "=", 2)
+		switch kv[0] {
+		case "-o", "--o", "-output", "--output":
+		default:
+			continue
+		}
+		var val string
+		if len(kv) == 2 {
+			val = kv[1]
+		} else if i+1 < len(ans) {
+			val = ans[i+1]
+		}
+		switch val {
+		case "", outputText, outputJSON, outputJSONL, outputCSV:
+		default:
+			ans[i] = strings.Replace(a, kv[0], "-of", 1)
+		}
+	}
+	return ans
+}
+
+// Export META of a video, as "export -i <id> [-o meta.json]".
+func onExport(args []string) {
+	os.Args = append(os.Args[:1], exportArgs(args)...)
+	getFlags()
+	uploader.Logf = logf
+	var id = parseString(f.Id, flag.Arg(0))
+	if id == "" {
+		printf("No video id to export!\n")
+		os.Exit(exitError)
+	}
+	if f.DryRun {
+		printDryRun(dryRunExport(id))
+		return
+	}
+	var out = f.OutputFile
+	var res = &jobResult{Event: "result", File: out, Started: time.Now()}
+	m, err := exportVideoMeta(getAPIClient().service, id)
+	if err == nil {
+		var buf bytes.Buffer
+		var enc = json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(&m)
+		if out == "" {
+			_, err = os.Stdout.Write(buf.Bytes())
+		} else {
+			err = ioutil.WriteFile(out, buf.Bytes(), 0644)
+		}
+	}
+	if err == nil && out != "" {
+		res.Action = "exported"
+		printf("Exported %s to '%s'\n", id, out)
+	}
+	res.finish(id, err)
+	if outputIsJSON() && out != "" {
+		writeOutput(res)
+		os.Exit(exitCode(err))
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
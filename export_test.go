@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExportVideoMeta(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var job = &videoJob{
+		Video:    writeTestFile(t, dir, "ep05.mp4", []byte("video")),
+		Captions: []CaptionMeta{{Language: "fr", File: writeTestFile(t, dir, "ep05.srt", []byte("1\n00:00:00,000 --> 00:00:01,000\nSalut\n"))}},
+	}
+	job.Meta, _ = ParseVideoMeta([]byte(`{"language": "fr", "audioLanguage": "en", "tags": ["show"], "playlistTitles": ["Episodes"]}`))
+	f.Title = "Episode 5"
+	id, err := api.runJob(job)
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	m, err := exportVideoMeta(api.service, id)
+	if err != nil {
+		t.Fatalf("exportVideoMeta: %v", err)
+	}
+	if m.Title != "Episode 5" || !reflect.DeepEqual(m.Tags, []string{"show"}) || m.PrivacyStatus != "private" {
+		t.Errorf("title, tags, privacy = %q, %v, %q", m.Title, m.Tags, m.PrivacyStatus)
+	}
+	// languages kept apart
+	if m.Language != "fr" || m.AudioLanguage != "en" {
+		t.Errorf("language, audio = %q, %q, want fr, en", m.Language, m.AudioLanguage)
+	}
+	if len(m.Captions) != 1 || m.Captions[0].Language != "fr" || m.Captions[0].File != "" {
+		t.Errorf("captions = %+v, want fr track without file", m.Captions)
+	}
+	if pl := fake.Playlists(); len(pl) != 1 || !reflect.DeepEqual(m.PlaylistIDs, []string{pl[0].Id}) {
+		t.Errorf("playlist ids = %v, want [%v]", m.PlaylistIDs, pl)
+	}
+	// same languages exported once
+	v, _ := fake.Video(id)
+	v.Snippet.DefaultAudioLanguage = "fr"
+	if m = videoMeta(v); m.Language != "fr" || m.AudioLanguage != "" {
+		t.Errorf("language, audio = %q, %q, want fr, none", m.Language, m.AudioLanguage)
+	}
+}
+
+func TestExportArgs(t *testing.T) {
+	var tests = []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-i", "x", "-o", "meta.json"}, []string{"-i", "x", "-of", "meta.json"}},
+		{[]string{"--output=meta.json", "-o", "json", "x"}, []string{"-of=meta.json", "-o", "json", "x"}},
+		{[]string{"-o", "jsonl", "x"}, []string{"-o", "jsonl", "x"}},
+		{[]string{"-of", "meta.json", "-m", "a.json"}, []string{"-of", "meta.json", "-m", "a.json"}},
+	}
+	for _, tt := range tests {
+		if got := exportArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("exportArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/golangf/youtubeuploader/fakeyt"
+)
+
+// Serve a fake YouTube API, for use with --api_endpoint.
+func onServeFake(args []string) {
+	fs := flag.NewFlagSet("serve-fake", flag.ExitOnError)
+	addr := fs.String("addr", parseString(os.Getenv("YOUTUBEUPLOADER_FAKE_ADDR"), "localhost:8090"), "set listen address")
+	fs.Parse(args)
+	fmt.Printf("Fake YouTube API listening on http://%s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, fakeyt.New()))
+}
@@ -0,0 +1,522 @@
+package fakeyt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Get requested parts of a resource, as "part=a,b" or "part=a&part=b".
+func parts(r *http.Request) map[string]bool {
+	var ans = map[string]bool{}
+	for _, v := range r.URL.Query()["part"] {
+		for _, p := range strings.Split(v, ",") {
+			ans[strings.TrimSpace(p)] = true
+		}
+	}
+	return ans
+}
+
+// Copy a video, with only the requested parts. Its etag depends on them,
+// as that of YouTube does.
+func videoParts(v *youtube.Video, p map[string]bool) *youtube.Video {
+	var ans = &youtube.Video{Kind: "youtube#video", Etag: v.Etag, Id: v.Id}
+	for _, nam := range []string{"snippet", "status", "recordingDetails", "localizations"} {
+		if p[nam] {
+			ans.Etag += "-" + nam
+		}
+	}
+	if p["snippet"] {
+		ans.Snippet = v.Snippet
+	}
+	if p["status"] {
+		ans.Status = v.Status
+	}
+	if p["recordingDetails"] {
+		ans.RecordingDetails = v.RecordingDetails
+	}
+	if p["localizations"] {
+		ans.Localizations = v.Localizations
+	}
+	return ans
+}
+
+func etag() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// Get default thumbnails of a video.
+func thumbnails(id string) *youtube.ThumbnailDetails {
+	var url = "https://i.ytimg.com/vi/" + id + "/"
+	return &youtube.ThumbnailDetails{
+		Default: &youtube.Thumbnail{Url: url + "default.jpg", Width: 120, Height: 90},
+		Medium:  &youtube.Thumbnail{Url: url + "mqdefault.jpg", Width: 320, Height: 180},
+		High:    &youtube.Thumbnail{Url: url + "hqdefault.jpg", Width: 480, Height: 360},
+	}
+}
+
+// Store a new video, with state locked.
+func (s *Server) addVideo(v *youtube.Video, size int64) *youtube.Video {
+	v.Id = s.newID("v")
+	v.Kind = "youtube#video"
+	v.Etag = etag()
+	if v.Snippet == nil {
+		v.Snippet = &youtube.VideoSnippet{}
+	}
+	if v.Status == nil {
+		v.Status = &youtube.VideoStatus{}
+	}
+	v.Snippet.PublishedAt = time.Now().UTC().Format(time.RFC3339)
+	v.Snippet.Thumbnails = thumbnails(v.Id)
+	v.Status.PrivacyStatus = parseString(v.Status.PrivacyStatus, "public")
+	v.Status.UploadStatus = "uploaded"
+	s.videos[v.Id] = v
+	s.sizes[v.Id] = size
+	return v
+}
+
+func parseString(txt string, def string) string {
+	if txt != "" {
+		return txt
+	}
+	return def
+}
+
+// Handle videos.insert, resumable or multipart.
+func (s *Server) insertVideo(w http.ResponseWriter, r *http.Request) {
+	var q = r.URL.Query()
+	if q.Get("upload_id") != "" {
+		s.putSession(w, r)
+		return
+	}
+	var v = &youtube.Video{}
+	if q.Get("uploadType") == "resumable" {
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "parseError", err.Error())
+			return
+		}
+		var size, _ = strconv.ParseInt(r.Header.Get("X-Upload-Content-Length"), 10, 64)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var id = s.newID("u")
+		s.sessions[id] = &session{video: v, size: size}
+		var scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		var loc = fmt.Sprintf("%s://%s%s?uploadType=resumable&upload_id=%s&%s", scheme, r.Host, r.URL.Path, id, url.Values{"part": q["part"]}.Encode())
+		w.Header().Set("Location", loc)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	media, err := readUpload(r, v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	if media == nil {
+		writeError(w, http.StatusBadRequest, "mediaBodyRequired", "Media body is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, videoParts(s.addVideo(v, int64(len(media))), parts(r)))
+}
+
+// Parse "bytes a-b/total" or "bytes */total".
+func parseContentRange(txt string) (start int64, end int64, total int64, ok bool) {
+	if !strings.HasPrefix(txt, "bytes ") {
+		return 0, -1, -1, txt == ""
+	}
+	txt = strings.TrimPrefix(txt, "bytes ")
+	var i = strings.Index(txt, "/")
+	if i < 0 {
+		return
+	}
+	total = -1
+	if txt[i+1:] != "*" {
+		var err error
+		if total, err = strconv.ParseInt(txt[i+1:], 10, 64); err != nil {
+			return
+		}
+	}
+	if txt[:i] == "*" {
+		return 0, -1, total, true
+	}
+	var rng = strings.Split(txt[:i], "-")
+	if len(rng) != 2 {
+		return
+	}
+	start, err1 := strconv.ParseInt(rng[0], 10, 64)
+	end, err2 := strconv.ParseInt(rng[1], 10, 64)
+	return start, end, total, err1 == nil && err2 == nil
+}
+
+// Handle a chunk or status query on a resumable session.
+func (s *Server) putSession(w http.ResponseWriter, r *http.Request) {
+	var id = r.URL.Query().Get("upload_id")
+	start, end, total, ok := parseContentRange(r.Header.Get("Content-Range"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "badContentRange", "Invalid Content-Range")
+		return
+	}
+	var n int64
+	if end >= start {
+		n, _ = io.Copy(ioutil.Discard, io.LimitReader(r.Body, end-start+1))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ses, ok := s.sessions[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "notFound", "Upload session not found")
+		return
+	}
+	if total >= 0 {
+		ses.size = total
+	}
+	// bytes not following those received are dropped
+	if end >= start && start == ses.received {
+		ses.received += n
+	}
+	if ses.size >= 0 && ses.received >= ses.size && ses.size > 0 {
+		delete(s.sessions, id)
+		writeJSON(w, http.StatusOK, videoParts(s.addVideo(ses.video, ses.received), parts(r)))
+		return
+	}
+	if ses.received > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", ses.received-1))
+	}
+	w.WriteHeader(308)
+}
+
+// Handle videos.update, replacing the given parts.
+func (s *Server) updateVideo(w http.ResponseWriter, r *http.Request) {
+	var v = &youtube.Video{}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.videos[v.Id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "videoNotFound", "Video not found: "+v.Id)
+		return
+	}
+	var p = parts(r)
+	if p["snippet"] && v.Snippet != nil {
+		v.Snippet.PublishedAt = old.Snippet.PublishedAt
+		v.Snippet.Thumbnails = old.Snippet.Thumbnails
+		old.Snippet = v.Snippet
+	}
+	if p["status"] && v.Status != nil {
+		v.Status.UploadStatus = old.Status.UploadStatus
+		old.Status = v.Status
+	}
+	if p["recordingDetails"] {
+		old.RecordingDetails = v.RecordingDetails
+	}
+	if p["localizations"] {
+		old.Localizations = v.Localizations
+	}
+	old.Etag = etag()
+	writeJSON(w, http.StatusOK, videoParts(old, p))
+}
+
+// Handle videos.list by id.
+func (s *Server) listVideos(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ans = &youtube.VideoListResponse{Kind: "youtube#videoListResponse", Items: []*youtube.Video{}}
+	var p = parts(r)
+	for _, id := range strings.Split(strings.Join(r.URL.Query()["id"], ","), ",") {
+		if v, ok := s.videos[id]; ok {
+			ans.Items = append(ans.Items, videoParts(v, p))
+		}
+	}
+	ans.PageInfo = &youtube.PageInfo{TotalResults: int64(len(ans.Items)), ResultsPerPage: int64(len(ans.Items))}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+// Handle thumbnails.set.
+func (s *Server) setThumbnail(w http.ResponseWriter, r *http.Request) {
+	var id = r.URL.Query().Get("videoId")
+	dat, err := readUpload(r, &struct{}{})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.videos[id]; !ok {
+		writeError(w, http.StatusNotFound, "videoNotFound", "Video not found: "+id)
+		return
+	}
+	s.thumbnails[id] = dat
+	var url = fmt.Sprintf("http://%s/vi/%s/default.jpg", r.Host, id)
+	writeJSON(w, http.StatusOK, &youtube.ThumbnailSetResponse{
+		Kind:  "youtube#thumbnailSetResponse",
+		Items: []*youtube.ThumbnailDetails{{Default: &youtube.Thumbnail{Url: url}}},
+	})
+}
+
+// Handle captions.insert.
+func (s *Server) insertCaption(w http.ResponseWriter, r *http.Request) {
+	var c = &youtube.Caption{}
+	if _, err := readUpload(r, c); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	if c.Snippet == nil {
+		writeError(w, http.StatusBadRequest, "invalidMetadata", "Caption snippet is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.videos[c.Snippet.VideoId]; !ok {
+		writeError(w, http.StatusNotFound, "videoNotFound", "Video not found: "+c.Snippet.VideoId)
+		return
+	}
+	for _, o := range s.captions {
+		if o.Snippet.VideoId == c.Snippet.VideoId && o.Snippet.Language == c.Snippet.Language && o.Snippet.Name == c.Snippet.Name {
+			writeError(w, http.StatusConflict, "captionExists", "Caption track already exists")
+			return
+		}
+	}
+	c.Id = s.newID("c")
+	c.Kind = "youtube#caption"
+	c.Etag = etag()
+	c.Snippet.Status = "serving"
+	c.Snippet.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+	s.captions[c.Id] = c
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Handle captions.list by video id.
+func (s *Server) listCaptions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var id = r.URL.Query().Get("videoId")
+	if _, ok := s.videos[id]; !ok {
+		writeError(w, http.StatusNotFound, "videoNotFound", "Video not found: "+id)
+		return
+	}
+	var ans = &youtube.CaptionListResponse{Kind: "youtube#captionListResponse", Items: []*youtube.Caption{}}
+	for _, c := range s.captions {
+		if c.Snippet.VideoId == id {
+			ans.Items = append(ans.Items, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+// Handle captions.update, with optional new content.
+func (s *Server) updateCaption(w http.ResponseWriter, r *http.Request) {
+	var c = &youtube.Caption{}
+	if _, err := readUpload(r, c); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.captions[c.Id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "captionNotFound", "Caption not found: "+c.Id)
+		return
+	}
+	if c.Snippet != nil {
+		old.Snippet.IsDraft = c.Snippet.IsDraft
+	}
+	old.Etag = etag()
+	old.Snippet.LastUpdated = time.Now().UTC().Format(time.RFC3339)
+	writeJSON(w, http.StatusOK, old)
+}
+
+// Handle playlists.list, of mine or by id.
+func (s *Server) listPlaylists(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ans = &youtube.PlaylistListResponse{Kind: "youtube#playlistListResponse", Items: []*youtube.Playlist{}}
+	var ids = strings.Join(r.URL.Query()["id"], ",")
+	for i := 1; i <= s.lastID; i++ {
+		pl, ok := s.playlists[fakeID("p", i)]
+		if ok && (ids == "" || strings.Contains(","+ids+",", ","+pl.Id+",")) {
+			ans.Items = append(ans.Items, pl)
+		}
+	}
+	ans.PageInfo = &youtube.PageInfo{TotalResults: int64(len(ans.Items)), ResultsPerPage: int64(len(ans.Items))}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+// Handle playlists.insert.
+func (s *Server) insertPlaylist(w http.ResponseWriter, r *http.Request) {
+	var pl = &youtube.Playlist{}
+	if err := json.NewDecoder(r.Body).Decode(pl); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	if pl.Snippet == nil || pl.Snippet.Title == "" {
+		writeError(w, http.StatusBadRequest, "playlistTitleRequired", "Playlist title is required")
+		return
+	}
+	if pl.Status == nil {
+		pl.Status = &youtube.PlaylistStatus{}
+	}
+	pl.Status.PrivacyStatus = parseString(pl.Status.PrivacyStatus, "public")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pl.Id = s.newID("p")
+	pl.Kind = "youtube#playlist"
+	pl.Etag = etag()
+	pl.ContentDetails = &youtube.PlaylistContentDetails{}
+	s.playlists[pl.Id] = pl
+	writeJSON(w, http.StatusOK, pl)
+}
+
+// Handle playlistItems.insert.
+func (s *Server) insertPlaylistItem(w http.ResponseWriter, r *http.Request) {
+	var itm = &youtube.PlaylistItem{}
+	if err := json.NewDecoder(r.Body).Decode(itm); err != nil {
+		writeError(w, http.StatusBadRequest, "parseError", err.Error())
+		return
+	}
+	if itm.Snippet == nil || itm.Snippet.ResourceId == nil {
+		writeError(w, http.StatusBadRequest, "invalidMetadata", "Playlist item snippet is required")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pl, ok := s.playlists[itm.Snippet.PlaylistId]
+	if !ok {
+		writeError(w, http.StatusNotFound, "playlistNotFound", "Playlist not found: "+itm.Snippet.PlaylistId)
+		return
+	}
+	if _, ok := s.videos[itm.Snippet.ResourceId.VideoId]; !ok {
+		writeError(w, http.StatusNotFound, "videoNotFound", "Video not found: "+itm.Snippet.ResourceId.VideoId)
+		return
+	}
+	itm.Id = s.newID("i")
+	itm.Kind = "youtube#playlistItem"
+	itm.Etag = etag()
+	itm.Snippet.Position = pl.ContentDetails.ItemCount
+	pl.ContentDetails.ItemCount++
+	s.items[itm.Id] = itm
+	writeJSON(w, http.StatusOK, itm)
+}
+
+// Get a page of items, by page token (offset) and max results (5).
+func page(r *http.Request, n int) (int, int, string) {
+	var start, _ = strconv.Atoi(r.URL.Query().Get("pageToken"))
+	var max, err = strconv.Atoi(r.URL.Query().Get("maxResults"))
+	if err != nil || max <= 0 {
+		max = 5
+	}
+	if start > n {
+		start = n
+	}
+	var end = start + max
+	if end >= n {
+		return start, n, ""
+	}
+	return start, end, strconv.Itoa(end)
+}
+
+// Id of the uploads playlist of the fake channel.
+const uploadsID = "UUfakechannel"
+
+// Handle channels.list, with the fake channel only.
+func (s *Server) listChannels(w http.ResponseWriter, r *http.Request) {
+	var c = &youtube.Channel{Kind: "youtube#channel", Etag: etag(), Id: "UCfakechannel"}
+	c.ContentDetails = &youtube.ChannelContentDetails{RelatedPlaylists: &youtube.ChannelContentDetailsRelatedPlaylists{Uploads: uploadsID}}
+	var ans = &youtube.ChannelListResponse{Kind: "youtube#channelListResponse", Items: []*youtube.Channel{c}}
+	ans.PageInfo = &youtube.PageInfo{TotalResults: 1, ResultsPerPage: 1}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+// Get items of the uploads playlist, newest first.
+func (s *Server) uploadItems() []*youtube.PlaylistItem {
+	var ans []*youtube.PlaylistItem
+	for i := s.lastID; i >= 1; i-- {
+		if v, ok := s.videos[fakeID("v", i)]; ok {
+			ans = append(ans, &youtube.PlaylistItem{
+				Kind:           "youtube#playlistItem",
+				Etag:           v.Etag,
+				Id:             fakeID("i", i),
+				ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: v.Id, VideoPublishedAt: v.Snippet.PublishedAt},
+				Snippet:        &youtube.PlaylistItemSnippet{PlaylistId: uploadsID, ResourceId: &youtube.ResourceId{Kind: "youtube#video", VideoId: v.Id}},
+			})
+		}
+	}
+	return ans
+}
+
+// Handle playlistItems.list, by playlist id and optional video id.
+func (s *Server) listPlaylistItems(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var q = r.URL.Query()
+	if q.Get("playlistId") == uploadsID {
+		writeItems(w, r, s.uploadItems())
+		return
+	}
+	if _, ok := s.playlists[q.Get("playlistId")]; !ok {
+		writeError(w, http.StatusNotFound, "playlistNotFound", "Playlist not found: "+q.Get("playlistId"))
+		return
+	}
+	var items []*youtube.PlaylistItem
+	for i := 1; i <= s.lastID; i++ {
+		itm, ok := s.items[fakeID("i", i)]
+		if ok && itm.Snippet.PlaylistId == q.Get("playlistId") && (q.Get("videoId") == "" || itm.Snippet.ResourceId.VideoId == q.Get("videoId")) {
+			items = append(items, itm)
+		}
+	}
+	writeItems(w, r, items)
+}
+
+// Write a page of playlist items.
+func writeItems(w http.ResponseWriter, r *http.Request, items []*youtube.PlaylistItem) {
+	start, end, next := page(r, len(items))
+	var ans = &youtube.PlaylistItemListResponse{Kind: "youtube#playlistItemListResponse", Items: items[start:end], NextPageToken: next}
+	if ans.Items == nil {
+		ans.Items = []*youtube.PlaylistItem{}
+	}
+	ans.PageInfo = &youtube.PageInfo{TotalResults: int64(len(items)), ResultsPerPage: int64(end - start)}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+// Handle search.list, matching video titles.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var q = strings.ToLower(r.URL.Query().Get("q"))
+	var ans = &youtube.SearchListResponse{Kind: "youtube#searchListResponse", Items: []*youtube.SearchResult{}}
+	for i := 1; i <= s.lastID; i++ {
+		v, ok := s.videos[fakeID("v", i)]
+		if !ok || !strings.Contains(strings.ToLower(v.Snippet.Title), q) {
+			continue
+		}
+		ans.Items = append(ans.Items, &youtube.SearchResult{
+			Kind: "youtube#searchResult",
+			Etag: v.Etag,
+			Id:   &youtube.ResourceId{Kind: "youtube#video", VideoId: v.Id},
+			Snippet: &youtube.SearchResultSnippet{
+				Title:       v.Snippet.Title,
+				Description: v.Snippet.Description,
+				PublishedAt: v.Snippet.PublishedAt,
+			},
+		})
+		if len(ans.Items) >= 50 {
+			break
+		}
+	}
+	ans.PageInfo = &youtube.PageInfo{TotalResults: int64(len(ans.Items)), ResultsPerPage: 50}
+	writeJSON(w, http.StatusOK, ans)
+}
@@ -0,0 +1,255 @@
+// Package fakeyt is an in-memory stand-in for the YouTube Data API, for
+// end-to-end tests of youtubeuploader (and its wrappers) without a Google account.
+package fakeyt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Server is a fake YouTube Data API server.
+type Server struct {
+	mu         sync.Mutex
+	lastID     int
+	videos     map[string]*youtube.Video
+	sizes      map[string]int64
+	thumbnails map[string][]byte
+	captions   map[string]*youtube.Caption
+	playlists  map[string]*youtube.Playlist
+	items      map[string]*youtube.PlaylistItem
+	sessions   map[string]*session
+	exhausted  map[string]bool
+}
+
+// session is a resumable upload in progress.
+type session struct {
+	video    *youtube.Video
+	size     int64
+	received int64
+}
+
+// New creates an empty fake server.
+func New() *Server {
+	return &Server{
+		videos:     map[string]*youtube.Video{},
+		sizes:      map[string]int64{},
+		thumbnails: map[string][]byte{},
+		captions:   map[string]*youtube.Caption{},
+		playlists:  map[string]*youtube.Playlist{},
+		items:      map[string]*youtube.PlaylistItem{},
+		sessions:   map[string]*session{},
+		exhausted:  map[string]bool{},
+	}
+}
+
+// ServeHTTP routes API requests. Handlers lock state once their request
+// body is read, so slow uploads don't hold other requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var pth = strings.TrimPrefix(r.URL.Path, "/upload")
+	pth = strings.TrimPrefix(pth, "/youtube/v3")
+	if s.isExhausted(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		writeError(w, http.StatusForbidden, "quotaExceeded", "The request cannot be completed because you have exceeded your quota.")
+		return
+	}
+	switch r.Method + " " + pth {
+	case "GET /o/oauth2/auth":
+		s.authorize(w, r)
+	case "POST /token":
+		s.token(w, r)
+	case "POST /videos":
+		s.insertVideo(w, r)
+	case "PUT /videos":
+		if r.URL.Query().Get("upload_id") != "" {
+			s.putSession(w, r)
+		} else {
+			s.updateVideo(w, r)
+		}
+	case "GET /videos":
+		s.listVideos(w, r)
+	case "POST /thumbnails/set":
+		s.setThumbnail(w, r)
+	case "GET /captions":
+		s.listCaptions(w, r)
+	case "POST /captions":
+		s.insertCaption(w, r)
+	case "PUT /captions":
+		s.updateCaption(w, r)
+	case "GET /playlists":
+		s.listPlaylists(w, r)
+	case "POST /playlists":
+		s.insertPlaylist(w, r)
+	case "GET /playlistItems":
+		s.listPlaylistItems(w, r)
+	case "POST /playlistItems":
+		s.insertPlaylistItem(w, r)
+	case "GET /channels":
+		s.listChannels(w, r)
+	case "GET /search":
+		s.search(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "notFound", "Unknown endpoint "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// ExhaustQuota makes requests with an access token fail, as out of quota.
+func (s *Server) ExhaustQuota(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exhausted[token] = true
+}
+
+func (s *Server) isExhausted(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exhausted[token]
+}
+
+// Videos returns all videos, in upload order.
+func (s *Server) Videos() []*youtube.Video {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ans []*youtube.Video
+	for i := 1; i <= s.lastID; i++ {
+		if v, ok := s.videos[fakeID("v", i)]; ok {
+			ans = append(ans, v)
+		}
+	}
+	return ans
+}
+
+// Video returns a video by id, and its uploaded size.
+func (s *Server) Video(id string) (*youtube.Video, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.videos[id], s.sizes[id]
+}
+
+// Thumbnail returns the thumbnail uploaded for a video.
+func (s *Server) Thumbnail(id string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.thumbnails[id]
+}
+
+// Captions returns the caption tracks of a video.
+func (s *Server) Captions(id string) []*youtube.Caption {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ans []*youtube.Caption
+	for _, c := range s.captions {
+		if c.Snippet.VideoId == id {
+			ans = append(ans, c)
+		}
+	}
+	return ans
+}
+
+// Playlists returns all playlists, in creation order.
+func (s *Server) Playlists() []*youtube.Playlist {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ans []*youtube.Playlist
+	for i := 1; i <= s.lastID; i++ {
+		if pl, ok := s.playlists[fakeID("p", i)]; ok {
+			ans = append(ans, pl)
+		}
+	}
+	return ans
+}
+
+// PlaylistItems returns the video ids in a playlist.
+func (s *Server) PlaylistItems(pid string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ans []string
+	for _, itm := range s.items {
+		if itm.Snippet.PlaylistId == pid {
+			ans = append(ans, itm.Snippet.ResourceId.VideoId)
+		}
+	}
+	return ans
+}
+
+func fakeID(pre string, n int) string {
+	return fmt.Sprintf("%s%010d", pre, n)
+}
+
+func (s *Server) newID(pre string) string {
+	s.lastID++
+	return fakeID(pre, s.lastID)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Write a Google API style error.
+func writeError(w http.ResponseWriter, code int, reason string, msg string) {
+	writeJSON(w, code, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": msg,
+			"errors": []map[string]string{
+				{"domain": "youtube", "reason": reason, "message": msg},
+			},
+		},
+	})
+}
+
+// Read request body as metadata JSON and media, for any upload type.
+func readUpload(r *http.Request, obj interface{}) (media []byte, err error) {
+	typ, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(typ, "multipart/") {
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		// thumbnails.set sends an empty metadata part
+		if err = json.NewDecoder(part).Decode(obj); err != nil && err != io.EOF {
+			return nil, err
+		}
+		part, err = mr.NextPart()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(part)
+	}
+	dat, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(typ, "application/json") {
+		return nil, json.Unmarshal(dat, obj)
+	}
+	return dat, nil
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	var q = r.URL.Query()
+	var u = q.Get("redirect_uri") + "?code=fake&state=" + q.Get("state")
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":  "fake-access-token",
+		"refresh_token": "fake-refresh-token",
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	})
+}
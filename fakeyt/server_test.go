@@ -0,0 +1,181 @@
+package fakeyt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+func newTestService(t *testing.T) (*Server, *httptest.Server, *youtube.Service) {
+	var fake = New()
+	var srv = httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	service, err := youtube.New(&http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = srv.URL + "/"
+	return fake, srv, service
+}
+
+func TestInsertVideo(t *testing.T) {
+	fake, _, service := newTestService(t)
+	var obj = &youtube.Video{Snippet: &youtube.VideoSnippet{Title: "a"}, Status: &youtube.VideoStatus{PrivacyStatus: "private"}}
+	v, err := service.Videos.Insert([]string{"snippet", "status"}, obj).Media(strings.NewReader("video"), googleapi.ChunkSize(0)).Do()
+	if err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if got, size := fake.Video(v.Id); got == nil || size != 5 || got.Status.UploadStatus != "uploaded" {
+		t.Fatalf("video %s = %+v, %d bytes", v.Id, got, size)
+	}
+	// only requested parts, etag depends on them
+	res, err := service.Videos.List([]string{"status"}).Id(v.Id).Do()
+	if err != nil || len(res.Items) != 1 {
+		t.Fatalf("list = %v, %v", res, err)
+	}
+	if res.Items[0].Snippet != nil || res.Items[0].Status.PrivacyStatus != "private" || res.Items[0].Etag == v.Etag {
+		t.Errorf("listed %+v, want status only, etag of parts", res.Items[0])
+	}
+	// ids are sent as repeated parameters
+	if res, err = service.Videos.List([]string{"id"}).Id(v.Id, "missing", v.Id).Do(); err != nil || len(res.Items) != 2 {
+		t.Errorf("list of several ids = %v, %v, want 2 items", res, err)
+	}
+	obj.Id, obj.Snippet.Title = v.Id, "b"
+	if _, err = service.Videos.Update([]string{"snippet"}, obj).Do(); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, _ := fake.Video(v.Id); got.Snippet.Title != "b" || got.Snippet.PublishedAt == "" {
+		t.Errorf("updated snippet = %+v", got.Snippet)
+	}
+	obj.Id = "missing"
+	if _, err = service.Videos.Update([]string{"snippet"}, obj).Do(); !strings.Contains(fmt.Sprint(err), "videoNotFound") {
+		t.Errorf("update of missing video = %v", err)
+	}
+}
+
+// Start a resumable upload session, and return its URI.
+func startSession(t *testing.T, srv *httptest.Server, size int) string {
+	req, _ := http.NewRequest("POST", srv.URL+"/upload/youtube/v3/videos?uploadType=resumable&part=snippet", strings.NewReader(`{"snippet":{"title":"a"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Upload-Content-Length", fmt.Sprint(size))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.Header.Get("Location")
+}
+
+func putChunk(t *testing.T, loc string, rng string, body io.Reader) *http.Response {
+	req, _ := http.NewRequest("PUT", loc, body)
+	req.Header.Set("Content-Range", rng)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestResumableSession(t *testing.T) {
+	fake, srv, _ := newTestService(t)
+	var loc = startSession(t, srv, 10)
+	if res := putChunk(t, loc, "bytes 0-3/10", strings.NewReader("vide")); res.StatusCode != 308 || res.Header.Get("Range") != "bytes=0-3" {
+		t.Fatalf("chunk = %d %q, want 308 bytes=0-3", res.StatusCode, res.Header.Get("Range"))
+	}
+	// bytes not following those received are dropped
+	if res := putChunk(t, loc, "bytes 6-9/10", strings.NewReader("data")); res.Header.Get("Range") != "bytes=0-3" {
+		t.Errorf("gap chunk range = %q, want bytes=0-3", res.Header.Get("Range"))
+	}
+	if res := putChunk(t, loc, "bytes */10", nil); res.StatusCode != 308 || res.Header.Get("Range") != "bytes=0-3" {
+		t.Errorf("status = %d %q, want 308 bytes=0-3", res.StatusCode, res.Header.Get("Range"))
+	}
+	if res := putChunk(t, loc, "bytes 4-9/10", strings.NewReader("o data")); res.StatusCode != http.StatusOK {
+		t.Fatalf("last chunk = %d, want 200", res.StatusCode)
+	}
+	if vs := fake.Videos(); len(vs) != 1 {
+		t.Fatalf("videos = %v, want 1", vs)
+	} else if _, size := fake.Video(vs[0].Id); size != 10 {
+		t.Errorf("size = %d, want 10", size)
+	}
+	// session is gone once complete
+	if res := putChunk(t, loc, "bytes */10", nil); res.StatusCode != http.StatusNotFound {
+		t.Errorf("status of completed session = %d, want 404", res.StatusCode)
+	}
+}
+
+func TestSlowUpload(t *testing.T) {
+	_, srv, service := newTestService(t)
+	var loc = startSession(t, srv, 10)
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	req, _ := http.NewRequest("PUT", loc, pr)
+	req.Header.Set("Content-Range", "bytes 0-9/10")
+	go http.DefaultClient.Do(req)
+	pw.Write([]byte("vid"))
+	// body being read does not hold other requests
+	var done = make(chan error, 1)
+	go func() {
+		_, err := service.Videos.List([]string{"id"}).Id("x").Do()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("list: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("list blocked by upload in progress")
+	}
+}
+
+func TestPlaylistItemsPaging(t *testing.T) {
+	_, _, service := newTestService(t)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		v, err := service.Videos.Insert([]string{"snippet"}, &youtube.Video{Snippet: &youtube.VideoSnippet{Title: "a"}}).Media(bytes.NewReader([]byte("v")), googleapi.ChunkSize(0)).Do()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append([]string{v.Id}, ids...)
+	}
+	var got []string
+	var pages int
+	err := service.PlaylistItems.List([]string{"contentDetails"}).PlaylistId(uploadsID).MaxResults(2).Pages(nil, func(res *youtube.PlaylistItemListResponse) error {
+		pages++
+		for _, itm := range res.Items {
+			got = append(got, itm.ContentDetails.VideoId)
+		}
+		return nil
+	})
+	if err != nil || pages != 3 || strings.Join(got, ",") != strings.Join(ids, ",") {
+		t.Errorf("uploads = %v in %d pages, %v, want %v newest first in 3", got, pages, err, ids)
+	}
+}
+
+func TestExhaustQuota(t *testing.T) {
+	fake, srv, _ := newTestService(t)
+	fake.ExhaustQuota("a")
+	for token, code := range map[string]int{"a": http.StatusForbidden, "b": http.StatusOK} {
+		req, _ := http.NewRequest("GET", srv.URL+"/youtube/v3/channels?part=id&mine=true", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(res.Body)
+		res.Body.Close()
+		if res.StatusCode != code || (code != http.StatusOK && !strings.Contains(body.String(), "quotaExceeded")) {
+			t.Errorf("token %s = %d %s, want %d", token, res.StatusCode, body.String(), code)
+		}
+	}
+}
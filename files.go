@@ -2,13 +2,7 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
-	"io"
 	"io/ioutil"
-	"log"
-	"net/http"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -30,114 +24,87 @@ func LoadVideoMeta(filename string, y *youtube.Video) (m VideoMeta) {
 	if filename != "" {
 		file, e := ioutil.ReadFile(filename)
 		if e != nil {
-			fmt.Printf("Error reading file '%s': %s\n", filename, e)
-			fmt.Println("Will use command line flags instead")
+			printf("Error reading file '%s': %s\n", filename, e)
+			printf("Will use command line flags instead\n")
 			goto errJump
 		}
 
-		e = json.Unmarshal(file, &m)
+		m, e = ParseVideoMeta(file)
 		if e != nil {
-			fmt.Printf("Error parsing file '%s': %s\n", filename, e)
-			fmt.Println("Will use command line flags instead")
+			printf("Error parsing file '%s': %s\n", filename, e)
+			printf("Will use command line flags instead\n")
 			goto errJump
 		}
-
-		e = json.Unmarshal(file, &m.JSON)
-		if e != nil {
-			fmt.Printf("Error parsing file '%s': %s\n", filename, e)
-			fmt.Println("Will use command line flags instead")
-			goto errJump
-		}
-
-		y.Status = &youtube.VideoStatus{}
-		y.Snippet.Tags = m.Tags
-		y.Snippet.Title = m.Title
-		y.Snippet.Description = m.Description
-		y.Snippet.CategoryId = m.CategoryId
-		if m.Location != nil {
-			y.RecordingDetails.Location = m.Location
-		}
-		if m.LocationDescription != "" {
-			y.RecordingDetails.LocationDescription = m.LocationDescription
-		}
-		if !m.RecordingDate.IsZero() {
-			y.RecordingDetails.RecordingDate = m.RecordingDate.UTC().Format(ytDateLayout)
-		}
-
-		// status
-		if m.PrivacyStatus != "" {
-			y.Status.PrivacyStatus = m.PrivacyStatus
-		}
-		if m.Embeddable {
-			y.Status.Embeddable = m.Embeddable
-		}
-		if m.License != "" {
-			y.Status.License = m.License
-		}
-		if m.PublicStatsViewable {
-			y.Status.PublicStatsViewable = m.PublicStatsViewable
-		}
-		if !m.PublishAt.IsZero() {
-			if y.Status.PrivacyStatus != "private" {
-				fmt.Printf("publishAt can only be used when privacyStatus is 'private'. Ignoring publishAt...\n")
-			} else {
-				if m.PublishAt.Before(time.Now()) {
-					fmt.Printf("publishAt (%s) was in the past!? Publishing now instead...\n", m.PublishAt)
-					y.Status.PublishAt = time.Now().UTC().Format(ytDateLayout)
-				} else {
-					y.Status.PublishAt = m.PublishAt.UTC().Format(ytDateLayout)
-				}
-			}
-		}
-		if m.Language != "" {
-			y.Snippet.DefaultLanguage = m.Language
-			y.Snippet.DefaultAudioLanguage = m.Language
+		if y != nil {
+			ApplyVideoMeta(&m, y)
 		}
 	}
 errJump:
 	return
 }
 
-// Open opens a file
-func Open(filename string) (io.ReadCloser, int64, error) {
-	var reader io.ReadCloser
-	var filesize int64
-	var err error
-	if strings.HasPrefix(filename, "http") {
-		resp, err := http.Head(filename)
-		if err != nil {
-			return reader, filesize, fmt.Errorf("error opening %s: %s", filename, err)
-		}
-		lenStr := resp.Header.Get("content-length")
-		if lenStr != "" {
-			filesize, err = strconv.ParseInt(lenStr, 10, 64)
-			if err != nil {
-				return reader, filesize, err
-			}
-		}
-
-		resp, err = http.Get(filename)
-		if err != nil {
-			return reader, filesize, fmt.Errorf("error opening %s: %s", filename, err)
-		}
-		if resp.ContentLength != 0 {
-			filesize = resp.ContentLength
-		}
-		reader = resp.Body
-		return reader, filesize, nil
+// ParseVideoMeta parses metaJSON
+func ParseVideoMeta(file []byte) (m VideoMeta, e error) {
+	e = json.Unmarshal(file, &m)
+	if e != nil {
+		return
 	}
+	e = json.Unmarshal(file, &m.JSON)
+	return
+}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		return reader, filesize, fmt.Errorf("error opening %s: %s", filename, err)
+// ApplyVideoMeta sets video fields from meta
+func ApplyVideoMeta(m *VideoMeta, y *youtube.Video) {
+	y.Status = &youtube.VideoStatus{}
+	y.Snippet.Tags = m.Tags
+	y.Snippet.Title = m.Title
+	y.Snippet.Description = m.Description
+	y.Snippet.CategoryId = m.CategoryId
+	if m.Location != nil {
+		y.RecordingDetails.Location = m.Location
+	}
+	if m.LocationDescription != "" {
+		y.RecordingDetails.LocationDescription = m.LocationDescription
+	}
+	if m.RecordingDate != nil {
+		y.RecordingDetails.RecordingDate = m.RecordingDate.UTC().Format(ytDateLayout)
 	}
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return reader, filesize, fmt.Errorf("error stat'ing %s: %s", filename, err)
+	// status
+	if m.PrivacyStatus != "" {
+		y.Status.PrivacyStatus = m.PrivacyStatus
+	}
+	if m.Embeddable {
+		y.Status.Embeddable = m.Embeddable
+	}
+	if m.License != "" {
+		y.Status.License = m.License
+	}
+	if m.PublicStatsViewable {
+		y.Status.PublicStatsViewable = m.PublicStatsViewable
+	}
+	if m.PublishAt != "" {
+		y.Status.PublishAt = m.PublishAt
 	}
+	if m.Language != "" {
+		y.Snippet.DefaultLanguage = m.Language
+		y.Snippet.DefaultAudioLanguage = m.Language
+	}
+	if m.AudioLanguage != "" {
+		y.Snippet.DefaultAudioLanguage = m.AudioLanguage
+	}
+	if len(m.Localizations) > 0 {
+		y.Localizations = m.Localizations
+	}
+}
 
-	return file, fileInfo.Size(), nil
+// MarshalJSON writes JSON, as date only at midnight UTC
+func (d Date) MarshalJSON() ([]byte, error) {
+	t := d.UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return json.Marshal(t.Format(inputDateLayout))
+	}
+	return json.Marshal(t.Format(inputDatetimeLayout))
 }
 
 // UnmarshalJSON reads JSON
@@ -152,16 +119,3 @@ func (d *Date) UnmarshalJSON(b []byte) (err error) {
 	}
 	return
 }
-
-func openFile(nam string) (io.ReadCloser, int64) {
-	var fil io.ReadCloser
-	var siz int64
-	var err error
-	if nam != "" {
-		fil, siz, err = Open(nam)
-		if err != nil {
-			log.Fatal(err)
-		}
-	}
-	return fil, siz
-}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"io/ioutil"
 	"log"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
 )
 
 //
@@ -18,12 +20,20 @@ type appFlags struct {
 	Help                bool
 	Version             bool
 	Log                 bool
+	Resume              bool
+	DryRun              bool
+	Strict              bool
+	Reupload            bool
+	HashTag             bool
 	Id                  string
 	Video               string
 	Thumbnail           string
 	Caption             string
 	DescriptionPath     string
 	Meta                string
+	Batch               string
+	Output              string
+	OutputFile          string
 	ClientID            string
 	ClientToken         string
 	Title               string
@@ -45,7 +55,25 @@ type appFlags struct {
 	UploadChunk         string
 	UploadRate          string
 	UploadTime          string
+	WatchInterval       string
+	Parallel            string
+	FilterTitle         string
+	FilterPrivacy       string
+	FilterTag           string
+	FilterAfter         string
+	FilterBefore        string
+	FilterStatus        string
+	FilterFile          string
+	MaxRetries          string
+	QuotaLimit          string
+	QuotaLedger         string
+	UploadLedger        string
+	HashSize            string
+	HistoryFile         string
+	RetryBudget         string
 	AuthPort            string
+	ApiEndpoint         string
+	ControlAddr         string
 	AuthHeadless        bool
 }
 type boolFlag struct {
@@ -59,23 +87,56 @@ type stringFlag struct {
 	Value *string
 }
 
+// listValue is a repeatable string flag, joining values with ";".
+// Values given on command line replace the environment default.
+type listValue struct {
+	Value *string
+	set   bool
+}
+
+func (v *listValue) String() string {
+	if v == nil || v.Value == nil {
+		return ""
+	}
+	return *v.Value
+}
+
+func (v *listValue) Set(txt string) error {
+	if !v.set || *v.Value == "" {
+		*v.Value = txt
+	} else {
+		*v.Value += ";" + txt
+	}
+	v.set = true
+	return nil
+}
+
 //
 // Global variables
 //
 var f = appFlags{}
 var fBool = map[string]boolFlag{
 	"log":                 {"l", "enable log", &f.Log},
+	"resume":              {"r", "resume interrupted video upload", &f.Resume},
+	"dry_run":             {"n", "print API requests instead of sending them", &f.DryRun},
+	"strict":              {"s", "refuse invalid metadata instead of truncating it", &f.Strict},
+	"reupload":            {"ru", "upload video even if uploaded before (by content hash)", &f.Reupload},
+	"hash_tag":            {"ht", "add content hash as video tag, to find uploads missing from ledger", &f.HashTag},
 	"embeddable":          {"oe", "enable video to be embeddable", &f.Embeddable},
 	"publicstatsviewable": {"os", "enable public video stats to be viewable", &f.PublicStatsViewable},
 	"auth_headless":       {"ah", "enable browserless OAuth process", &f.AuthHeadless},
 }
+var fList = map[string]bool{"caption": true}
 var fString = map[string]stringFlag{
 	"id":                  {"i", "set video id", &f.Id},
 	"video":               {"v", "set input video file", &f.Video},
 	"thumbnail":           {"t", "set input thumbnail file", &f.Thumbnail},
-	"caption":             {"c", "set input caption file", &f.Caption},
+	"caption":             {"c", "add input caption file, as [lang:]path[:name[:draft,nosync]]", &f.Caption},
 	"descriptionpath":     {"d", "set input description file", &f.DescriptionPath},
 	"meta":                {"m", "set input meta file", &f.Meta},
+	"batch":               {"b", "set input batch manifest file (.jsonl, .csv)", &f.Batch},
+	"output":              {"o", "set output format (text, json, jsonl, csv)", &f.Output},
+	"output_file":         {"of", "set output file of export (stdout)", &f.OutputFile},
 	"client_id":           {"ci", "set client id credentials path (client_id.json)", &f.ClientID},
 	"client_token":        {"ct", "set client token credentials path (client_token.json)", &f.ClientToken},
 	"title":               {"ot", "set video title (video)", &f.Title},
@@ -94,19 +155,37 @@ var fString = map[string]stringFlag{
 	"locationdescription": {"old", "set video location description", &f.LocationDescription},
 	"upload_chunk":        {"uc", "set upload chunk size in bytes", &f.UploadChunk},
 	"upload_rate":         {"ur", "set upload rate limit in kbps", &f.UploadRate},
-	"upload_time":         {"ut", "set upload time limit ex- \"10:00-14:00\"", &f.UploadTime},
+	"upload_time":         {"ut", "set upload schedule ex- \"10:00-14:00\", \"Mon-Fri 09:00-18:00=2000kbps; Sat,Sun=off\"", &f.UploadTime},
+	"watch_interval":      {"wi", "set watch directory poll interval (10s)", &f.WatchInterval},
+	"parallel":            {"pa", "set number of videos uploaded at once, by batch and watch (1)", &f.Parallel},
+	"filter_title":        {"ft", "list videos with title matching regexp", &f.FilterTitle},
+	"filter_privacy":      {"fp", "list videos with privacy status ex- \"private,unlisted\"", &f.FilterPrivacy},
+	"filter_tag":          {"fk", "list videos with tag", &f.FilterTag},
+	"filter_after":        {"fa", "list videos published after time ex- \"-30d\"", &f.FilterAfter},
+	"filter_before":       {"fb", "list videos published before time", &f.FilterBefore},
+	"filter_status":       {"fs", "list history with status (ok, failed)", &f.FilterStatus},
+	"filter_file":         {"ff", "list history of files matching glob ex- \"*.mp4\"", &f.FilterFile},
+	"max_retries":         {"mr", "set max retries of an API request (8)", &f.MaxRetries},
+	"retry_budget":        {"rb", "set total retry wait time ex- \"10m\" (no limit)", &f.RetryBudget},
+	"quota_limit":         {"ql", "set daily quota units per client id (10000)", &f.QuotaLimit},
+	"quota_ledger":        {"qf", "set quota ledger path (client_quota.json)", &f.QuotaLedger},
+	"upload_ledger":       {"ul", "set upload ledger path (client_uploads.json)", &f.UploadLedger},
+	"hash_size":           {"hs", "hash only first and last N MB of video files (whole file)", &f.HashSize},
+	"history_file":        {"hf", "set history file path (client_history.jsonl)", &f.HistoryFile},
 	"auth_port":           {"ap", "set OAuth request port (8080)", &f.AuthPort},
+	"api_endpoint":        {"ae", "set API endpoint base URL (googleapis.com)", &f.ApiEndpoint},
+	"control_addr":        {"ca", "set control endpoint socket path or address ex- \"localhost:8091\" (none)", &f.ControlAddr},
 }
 
 //
 // Functions
 //
 func getFlagsDynamic() {
-	var ai = strings.Split(f.ClientID, ";")
-	var at = strings.Split(f.ClientToken, ";")
-	var n = rand.Intn(65535)
-	f.ClientID = ai[n%len(ai)]
-	f.ClientToken = at[n%len(at)]
+	credentialDir = filepath.Dir(strings.Split(f.ClientToken, ";")[0])
+	uploader.StateDir = credentialDir
+	credentials = getCredentials(f.ClientID, f.ClientToken)
+	f.ClientID = credentials[0].ID
+	f.ClientToken = credentials[0].Token
 	if f.Description == "" && f.DescriptionPath != "" {
 		dat, err := ioutil.ReadFile(f.DescriptionPath)
 		if err != nil {
@@ -132,6 +211,12 @@ func getFlags() {
 		flag.BoolVar(bf.Value, k, bv, bf.Usage)
 	}
 	for k, sf := range fString {
+		if fList[k] {
+			var lv = &listValue{Value: sf.Value}
+			flag.Var(lv, sf.Short, sf.Usage)
+			flag.Var(lv, k, sf.Usage)
+			continue
+		}
 		flag.StringVar(sf.Value, sf.Short, "", sf.Usage)
 		flag.StringVar(sf.Value, k, "", sf.Usage)
 	}
@@ -141,17 +226,22 @@ func getFlags() {
 	flag.Parse()
 	getFlagsBasic()
 	getFlagsDynamic()
+	setOutput()
 }
 
-func getUploadTime() limitRange {
-	var ans limitRange
-	var err error
-	if f.UploadTime != "" {
-		ans, err = parseLimitBetween(f.UploadTime)
-		if err != nil {
-			fmt.Printf("Invalid upload time: %v", err)
-			os.Exit(1)
-		}
+func getUploadSchedule() limitSchedule {
+	rate, err := parseLimitRate(parseString(f.UploadRate, "0"))
+	if err != nil {
+		printf("Invalid upload rate: %v\n", err)
+		os.Exit(1)
+	}
+	if f.UploadTime == "" {
+		return limitSchedule{{days: [7]bool{true, true, true, true, true, true, true}, rate: rate}}
+	}
+	ans, err := parseLimitSchedule(f.UploadTime, rate)
+	if err != nil {
+		printf("Invalid upload time: %v\n", err)
+		os.Exit(1)
 	}
 	return ans
 }
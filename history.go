@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+// historyRecord is an operation on a video, stored in history.
+type historyRecord struct {
+	Op         string    `json:"op"`
+	Started    time.Time `json:"started"`
+	Finished   time.Time `json:"finished"`
+	File       string    `json:"file,omitempty"`
+	Hash       string    `json:"hash,omitempty"`
+	Id         string    `json:"id,omitempty"`
+	Credential string    `json:"credential,omitempty"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	// elapsed time in seconds, average rate in bytes/s
+	Duration float64      `json:"duration"`
+	Rate     int64        `json:"rate,omitempty"`
+	Status   string       `json:"status"`
+	Error    *outputError `json:"error,omitempty"`
+}
+
+// History operations
+const (
+	historyUpload    = "upload"
+	historyUpdate    = "update"
+	historyThumbnail = "thumbnail"
+	historyCaption   = "caption"
+	historyPlaylist  = "playlist"
+)
+
+// History records are appended one at a time.
+var historyMu sync.Mutex
+
+// CSV columns of history records.
+var historyColumns = []string{"started", "finished", "op", "status", "file", "id", "hash", "credential", "bytes", "duration", "rate", "error"}
+
+func getHistoryPath() string {
+	return parseString(f.HistoryFile, credentialPath("client_history.jsonl"))
+}
+
+// Record an operation in history, finished now with an error or not.
+func addHistory(r *historyRecord, err error) {
+	if f.DryRun || validateOnly {
+		return
+	}
+	r.Finished = time.Now()
+	r.Duration = r.Finished.Sub(r.Started).Seconds()
+	if r.Bytes > 0 && r.Duration > 0 {
+		r.Rate = int64(float64(r.Bytes) / r.Duration)
+	}
+	r.Credential = parseString(r.Credential, f.ClientID)
+	r.Status = "ok"
+	if err != nil {
+		r.Status = "failed"
+		r.Error = newOutputError(err)
+	}
+	dat, _ := json.Marshal(r)
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	fil, err := os.OpenFile(getHistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = fil.Write(append(dat, '\n'))
+		fil.Close()
+	}
+	if err != nil {
+		printf("Error saving history: %v\n", err)
+	}
+}
+
+// Read all history records, oldest first.
+func readHistory(pth string) ([]*historyRecord, error) {
+	fil, err := os.Open(pth)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fil.Close()
+	var ans []*historyRecord
+	var sc = bufio.NewScanner(fil)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		var r = &historyRecord{}
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		// a line cut short by a crash doesn't hide the others
+		if err := json.Unmarshal(sc.Bytes(), r); err != nil {
+			printf("Warning: '%s' line %d skipped: %v\n", pth, n, err)
+			continue
+		}
+		ans = append(ans, r)
+	}
+	return ans, sc.Err()
+}
+
+// historyFilter selects history records, empty fields match all.
+type historyFilter struct {
+	Status string
+	File   string
+	Id     string
+	After  time.Time
+	Before time.Time
+}
+
+// Get filter of history records, as set by flags.
+func getHistoryFilter(now time.Time) (historyFilter, error) {
+	var ans = historyFilter{Status: strings.ToLower(f.FilterStatus), File: f.FilterFile, Id: f.Id}
+	var err error
+	if ans.Status != "" && ans.Status != "ok" && ans.Status != "failed" {
+		return ans, fmt.Errorf("Invalid status filter '%s' (ok, failed)", f.FilterStatus)
+	}
+	if f.FilterAfter != "" {
+		if ans.After, err = parsePublishAt(f.FilterAfter, now); err != nil {
+			return ans, fmt.Errorf("Invalid after filter: %v", err)
+		}
+	}
+	if f.FilterBefore != "" {
+		if ans.Before, err = parsePublishAt(f.FilterBefore, now); err != nil {
+			return ans, fmt.Errorf("Invalid before filter: %v", err)
+		}
+	}
+	return ans, nil
+}
+
+// Check if a file path matches a glob pattern, or its base name does.
+func fileMatch(pat string, pth string) bool {
+	if ok, _ := filepath.Match(pat, pth); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pat, filepath.Base(pth))
+	return ok
+}
+
+// Check if a history record matches the filter.
+func (h *historyFilter) match(r *historyRecord) bool {
+	switch {
+	case h.Status != "" && r.Status != h.Status:
+		return false
+	case h.File != "" && !fileMatch(h.File, r.File):
+		return false
+	case h.Id != "" && r.Id != h.Id:
+		return false
+	case !h.After.IsZero() && r.Started.Before(h.After):
+		return false
+	case !h.Before.IsZero() && !r.Started.Before(h.Before):
+		return false
+	}
+	return true
+}
+
+// Get error message of a history record, if any.
+func (r *historyRecord) message() string {
+	if r.Error == nil {
+		return ""
+	}
+	return r.Error.Message
+}
+
+// Write history records as a table.
+func writeHistoryTable(recs []*historyRecord) {
+	var w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "STARTED\tOP\tSTATUS\tID\tFILE\tTIME\tERROR\n")
+	for _, r := range recs {
+		var dur = time.Duration(r.Duration * float64(time.Second)).Round(time.Millisecond)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\t%s\n", r.Started.Local().Format("2006-01-02 15:04:05"), r.Op, r.Status, r.Id, r.File, dur, r.message())
+	}
+	w.Flush()
+}
+
+// Write history records as CSV, with history columns.
+func writeHistoryCSV(recs []*historyRecord) error {
+	var w = csv.NewWriter(os.Stdout)
+	w.Write(historyColumns)
+	for _, r := range recs {
+		w.Write([]string{
+			r.Started.Format(time.RFC3339), r.Finished.Format(time.RFC3339), r.Op, r.Status, r.File, r.Id, r.Hash, r.Credential,
+			fmt.Sprint(r.Bytes), fmt.Sprint(r.Duration), fmt.Sprint(r.Rate), r.message(),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Show operations done on videos, as "history [filters]".
+func onHistory(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	getFlags()
+	uploader.Logf = logf
+	h, err := getHistoryFilter(time.Now())
+	if err != nil {
+		printf("%v\n", err)
+		os.Exit(exitError)
+	}
+	recs, err := readHistory(getHistoryPath())
+	if err != nil {
+		printf("Error reading history '%s': %v\n", getHistoryPath(), err)
+		os.Exit(exitError)
+	}
+	var ans = []*historyRecord{}
+	for _, r := range recs {
+		if h.match(r) {
+			ans = append(ans, r)
+		}
+	}
+	switch f.Output {
+	case outputJSON:
+		writeOutput(ans)
+	case outputJSONL:
+		for _, r := range ans {
+			writeOutput(r)
+		}
+	case outputCSV:
+		err = writeHistoryCSV(ans)
+	default:
+		writeHistoryTable(ans)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
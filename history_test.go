@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestReadHistorySkipsBadLines(t *testing.T) {
+	var pth = writeTestFile(t, t.TempDir(), "client_history.jsonl", []byte(`{"op":"upload","file":"a.mp4","status":"ok"}
+
+{"op":"thumbnail","file":"a.jp
+{"op":"caption","file":"a.srt","status":"failed"}
+`))
+	recs, err := readHistory(pth)
+	if err != nil {
+		t.Fatalf("readHistory: %v", err)
+	}
+	if len(recs) != 2 || recs[0].Op != historyUpload || recs[1].Op != historyCaption {
+		t.Errorf("records = %d, want upload and caption", len(recs))
+	}
+}
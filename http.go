@@ -1,9 +1,10 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/porjo/go-flowrate/flowrate"
 	"google.golang.org/api/youtube/v3"
@@ -14,15 +15,15 @@ import (
 //
 type limitTransport struct {
 	rt       http.RoundTripper
-	lr       limitRange
+	bw       *bandwidth
+	mu       sync.Mutex
 	reader   *flowrate.Reader
 	filesize int64
-}
-
-type Playlistx struct {
-	Id            string
-	Title         string
-	PrivacyStatus string
+	// share of upload rate, relative to other uploads (1)
+	weight int
+	// upload in progress, canceled by control endpoint
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 type VideoMeta struct {
@@ -37,12 +38,12 @@ type VideoMeta struct {
 	Embeddable          bool   `json:"embeddable,omitempty"`
 	License             string `json:"license,omitempty"`
 	PublicStatsViewable bool   `json:"publicStatsViewable,omitempty"`
-	PublishAt           Date   `json:"publishAt,omitempty"`
+	PublishAt           string `json:"publishAt,omitempty"`
 
 	// recording details
 	Location            *youtube.GeoPoint `json:"location,omitempty"`
 	LocationDescription string            `json:"locationDescription,omitempty"`
-	RecordingDate       Date              `json:"recordingDate,omitempty"`
+	RecordingDate       *Date             `json:"recordingDate,omitempty"`
 
 	// PlaylistID is deprecated in favour of PlaylistIDs
 	PlaylistID     string   `json:"playlistId,omitempty"`
@@ -52,8 +53,20 @@ type VideoMeta struct {
 	// BCP-47 language code e.g. 'en','es'
 	Language string `json:"language,omitempty"`
 
+	// BCP-47 language code of audio, if not language
+	AudioLanguage string `json:"audioLanguage,omitempty"`
+
+	// localized title and description, by BCP-47 language code
+	Localizations map[string]youtube.VideoLocalization `json:"localizations,omitempty"`
+
+	// caption tracks
+	Captions []CaptionMeta `json:"captions,omitempty"`
+
+	// thumbnail URLs by size, written by export (not uploaded)
+	Thumbnails map[string]string `json:"thumbnails,omitempty"`
+
 	// JSON map
-	JSON map[string]interface{}
+	JSON map[string]interface{} `json:"-"`
 }
 
 //
@@ -71,6 +84,21 @@ func (t *limitTransport) RoundTrip(r *http.Request) (res *http.Response, err err
 		strings.HasPrefix(r.Header.Get("Content-Type"), "video") {
 		var monitor *flowrate.Monitor
 
+		t.mu.Lock()
+		var ctx = t.ctx
+		t.mu.Unlock()
+		if ctx == nil {
+			ctx = r.Context()
+		}
+
+		// don't start sending while paused, by upload schedule or control
+		// (a request being sent stalls in limitChecker.Read)
+		if err := t.bw.wait(ctx); err != nil {
+			r.Body.Close()
+			return nil, err
+		}
+
+		t.mu.Lock()
 		if t.reader != nil {
 			monitor = t.reader.Monitor
 		}
@@ -84,58 +112,60 @@ func (t *limitTransport) RoundTrip(r *http.Request) (res *http.Response, err err
 		} else {
 			t.reader.Monitor.SetTransferSize(t.filesize)
 		}
-		r.Body = &limitChecker{t.lr, t.reader}
+		t.bw.add(t.reader, t.weight)
+		r.Body = &limitChecker{t.bw, t.reader, ctx}
+		t.mu.Unlock()
 	}
 
 	return t.rt.RoundTrip(r)
 }
 
-func (plx *Playlistx) AddVideoToPlaylist(service *youtube.Service, videoID string) (err error) {
-	listCall := service.Playlists.List([]string{"snippet", "contentDetails"})
-	listCall = listCall.Mine(true)
-	response, err := listCall.Do()
-	if err != nil {
-		return fmt.Errorf("Error retrieving playlists: %s", err)
-	}
+// Start tracking a new upload, of a file size and weight. It is sent
+// with the returned context, until canceled.
+func (t *limitTransport) reset(ctx context.Context, size int64, weight int) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reader = nil
+	t.filesize = size
+	t.weight = weight
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	return t.ctx
+}
 
-	var playlist *youtube.Playlist
-	for _, pl := range response.Items {
-		if pl.Id == plx.Id || pl.Snippet.Title == plx.Title {
-			playlist = pl
-			break
-		}
+// Cancel the upload in progress, if any.
+func (t *limitTransport) abort() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
 	}
+}
 
-	// create playlist if it doesn't exist
-	if playlist == nil {
-		if plx.Id != "" {
-			return fmt.Errorf("Playlist ID '%s' doesn't exist", plx.Id)
-		}
-		playlist = &youtube.Playlist{}
-		playlist.Snippet = &youtube.PlaylistSnippet{Title: plx.Title}
-		playlist.Status = &youtube.PlaylistStatus{PrivacyStatus: plx.PrivacyStatus}
-		insertCall := service.Playlists.Insert([]string{"snippet", "status"}, playlist)
-		// API doesn't return playlist ID here!?
-		playlist, err = insertCall.Do()
-		if err != nil {
-			return fmt.Errorf("Error creating playlist with title '%s': %s", plx.Title, err)
-		}
+// Get status of the upload in progress, and its file size.
+func (t *limitTransport) status() (flowrate.Status, int64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reader == nil {
+		return flowrate.Status{}, t.filesize, false
 	}
+	return t.reader.Monitor.Status(), t.filesize, true
+}
 
-	playlistItem := &youtube.PlaylistItem{}
-	playlistItem.Snippet = &youtube.PlaylistItemSnippet{PlaylistId: playlist.Id, Title: playlist.Snippet.Title}
-	playlistItem.Snippet.ResourceId = &youtube.ResourceId{
-		VideoId: videoID,
-		Kind:    "youtube#video",
+// Finish tracking the upload, and get bytes sent.
+func (t *limitTransport) done() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
 	}
-
-	insertCall := service.PlaylistItems.Insert([]string{"snippet"}, playlistItem)
-	_, err = insertCall.Do()
-	if err != nil {
-		return err
+	t.ctx, t.cancel = nil, nil
+	if t.reader == nil {
+		return 0
 	}
+	return t.reader.Monitor.Done()
+}
 
-	logf("Video added to playlist '%s' (%s)\n", playlist.Snippet.Title, playlist.Id)
-
-	return nil
+// Get URL of a path on the custom API endpoint.
+func endpointURL(pth string) string {
+	return strings.TrimSuffix(f.ApiEndpoint, "/") + "/" + pth
 }
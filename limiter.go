@@ -1,77 +1,395 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/porjo/go-flowrate/flowrate"
 )
 
-type limitRange struct {
-	start time.Time
-	end   time.Time
+// Upload rates of schedule windows, in B/s (or no limit, or paused).
+const (
+	rateUnlimited int64 = 0
+	rateOff       int64 = -1
+)
+
+// How often a paused upload checks the schedule again.
+const pausePoll = time.Minute
+
+// limitWindow is an upload rate on some week days, between two local times
+// in minutes of day. It spans midnight if end is before start, and the
+// whole day if they are equal.
+type limitWindow struct {
+	days  [7]bool
+	start int
+	end   int
+	rate  int64
 }
 
+// limitSchedule is upload rate windows, the first matching applies, with
+// windows of some days before those of every day (no limit outside all).
+type limitSchedule []limitWindow
+
 type limitChecker struct {
-	limitRange
+	bw     *bandwidth
 	reader *flowrate.Reader
+	ctx    context.Context
 }
 
-func (lc *limitChecker) Read(p []byte) (n int, err error) {
-	if lc.start.IsZero() || lc.end.IsZero() {
-		lc.reader.SetLimit(int64(parseInt(f.UploadRate, 0) * 125))
-		return lc.reader.Read(p)
+// bandwidth shares the upload rate limit between concurrent uploads, by
+// weight (priority). The limit applies to all as set by upload schedule,
+// unless set or paused by control endpoint.
+type bandwidth struct {
+	mu         sync.Mutex
+	sched      limitSchedule
+	override   int64
+	overridden bool
+	held       bool
+	paused     bool
+	weights    map[*flowrate.Reader]int
+	// closed on control changes, to wake paused uploads
+	wake chan struct{}
+	// clock of schedule windows
+	now func() time.Time
+}
+
+// Shared by all API clients, created on first use.
+var uploadBandwidth *bandwidth
+var uploadBandwidthOnce sync.Once
+
+func getBandwidth() *bandwidth {
+	uploadBandwidthOnce.Do(func() {
+		uploadBandwidth = newBandwidth(getUploadSchedule())
+	})
+	return uploadBandwidth
+}
+
+func newBandwidth(sched limitSchedule) *bandwidth {
+	return &bandwidth{sched: sched, weights: map[*flowrate.Reader]int{}, wake: make(chan struct{}), now: time.Now}
+}
+
+// Add an upload stream, with a weight.
+func (b *bandwidth) add(r *flowrate.Reader, weight int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if weight <= 0 {
+		weight = 1
 	}
+	b.weights[r] = weight
+}
 
-	now := time.Now()
+// Remove an upload stream, once sent.
+func (b *bandwidth) remove(r *flowrate.Reader) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.weights, r)
+}
 
-	if now.Sub(lc.start) >= time.Hour*24 {
-		lc.start = lc.start.AddDate(0, 0, 1)
-		lc.end = lc.end.AddDate(0, 0, 1)
+// Get upload rate at a time in B/s, as set by control or schedule.
+func (b *bandwidth) rateAt(now time.Time) int64 {
+	switch {
+	case b.held:
+		return rateOff
+	case b.overridden:
+		return b.override
 	}
+	return b.sched.rate(now)
+}
+
+// Get upload rate now, and whether it is set by control.
+func (b *bandwidth) rate() (int64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rateAt(b.now()), b.held || b.overridden
+}
+
+// Set upload rate, or follow schedule again.
+func (b *bandwidth) setRate(rate int64, schedule bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.override, b.overridden = rate, !schedule
+	b.notify()
+}
+
+// Pause or resume all uploads, keeping rate.
+func (b *bandwidth) hold(held bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.held = held
+	b.notify()
+}
 
-	if lc.start.Before(now) && lc.end.After(now) {
-		// kbit/s to B/s = 1000/8 = 125
-		lc.reader.SetLimit(int64(parseInt(f.UploadRate, 0) * 125))
-	} else {
-		lc.reader.SetLimit(0)
+func (b *bandwidth) notify() {
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+// Get rate limit of an upload stream in B/s, its share of upload rate
+// now (0 for no limit, or paused).
+func (b *bandwidth) limit(r *flowrate.Reader) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var rate = b.rateAt(b.now())
+	if rate <= 0 {
+		return 0
+	}
+	var sum = 0
+	for _, w := range b.weights {
+		sum += w
 	}
+	if sum == 0 || b.weights[r] == 0 {
+		return rate
+	}
+	// zero means no limit, so keep at least 1 B/s
+	if ans := rate * int64(b.weights[r]) / int64(sum); ans > 0 {
+		return ans
+	}
+	return 1
+}
 
+// Wait while uploads are paused by schedule or control, checking schedule
+// again at window boundaries, until canceled.
+func (b *bandwidth) wait(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var now = b.now()
+		var until time.Time
+		b.mu.Lock()
+		var paused = b.rateAt(now) == rateOff
+		var ctl = b.held || b.overridden
+		var wake = b.wake
+		if paused && !ctl {
+			until = b.sched.until(now)
+		}
+		if paused != b.paused {
+			b.paused = paused
+			if !paused {
+				logf("Upload resumed\n")
+			} else if until.IsZero() {
+				logf("Upload paused\n")
+			} else {
+				logf("Upload paused until %s\n", until.Format("Mon 15:04"))
+			}
+		}
+		b.mu.Unlock()
+		if !paused {
+			return nil
+		}
+		var d = pausePoll
+		if !until.IsZero() && until.Sub(now) < d {
+			d = until.Sub(now)
+		}
+		select {
+		case <-time.After(d):
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Read stalls while paused, even in the middle of a chunk or a single
+// request upload.
+func (lc *limitChecker) Read(p []byte) (n int, err error) {
+	if err := lc.bw.wait(lc.ctx); err != nil {
+		return 0, err
+	}
+	lc.reader.SetLimit(lc.bw.limit(lc.reader))
 	return lc.reader.Read(p)
 }
 
 func (lc *limitChecker) Close() error {
+	lc.bw.remove(lc.reader)
 	return nil
 }
 
-func parseLimitBetween(between string) (limitRange, error) {
-	var lr limitRange
-	var err error
-	var start, end time.Time
-	parts := strings.Split(between, "-")
-	if len(parts) != 2 {
-		return lr, fmt.Errorf("limitBetween should have 2 parts separated by a hyphen")
+// Check if a window is for every day of the week.
+func (w *limitWindow) daily() bool {
+	for _, ok := range w.days {
+		if !ok {
+			return false
+		}
 	}
+	return true
+}
 
-	now := time.Now()
+// Get upload rate at a time, of the first window having it. Days and times
+// are those of the local clock, so windows follow DST changes.
+func (s limitSchedule) rate(now time.Time) int64 {
+	var day = now.Weekday()
+	var prev = (day + 6) % 7
+	var min = now.Hour()*60 + now.Minute()
+	for _, w := range s {
+		switch {
+		case w.start == w.end && w.days[day],
+			w.start < w.end && w.days[day] && w.start <= min && min < w.end,
+			w.start > w.end && w.days[day] && min >= w.start,
+			w.start > w.end && w.days[prev] && min < w.end:
+			return w.rate
+		}
+	}
+	return rateUnlimited
+}
 
-	start, err = time.ParseInLocation(inputTimeLayout, parts[0], time.Local)
-	if err != nil {
-		return lr, fmt.Errorf("limitBetween start time was invalid: %v", err)
+// Get next window start or end after a time, within a week.
+func (s limitSchedule) next(now time.Time) time.Time {
+	var ans time.Time
+	for d := 0; d <= 7; d++ {
+		for _, w := range s {
+			for _, m := range []int{w.start, w.end} {
+				t := time.Date(now.Year(), now.Month(), now.Day()+d, m/60, m%60, 0, 0, now.Location())
+				if t.After(now) && (ans.IsZero() || t.Before(ans)) {
+					ans = t
+				}
+			}
+		}
+	}
+	return ans
+}
+
+// Get time the upload rate changes after a time, zero if it never does.
+func (s limitSchedule) until(now time.Time) time.Time {
+	var rate = s.rate(now)
+	var end = now.AddDate(0, 0, 8)
+	for t := s.next(now); !t.IsZero() && t.Before(end); t = s.next(t) {
+		if s.rate(t) != rate {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Get a week day from its name, as "monday" or "mon".
+func parseWeekday(txt string) (time.Weekday, error) {
+	var txt0 = strings.ToLower(txt)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		var nam = strings.ToLower(d.String())
+		if txt0 == nam || txt0 == nam[:3] {
+			return d, nil
+		}
 	}
-	lr.start = time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.Local)
+	return 0, fmt.Errorf("'%s' is not a week day", txt)
+}
 
-	end, err = time.ParseInLocation(inputTimeLayout, parts[1], time.Local)
+// Parse week days, as "Mon-Fri", "Sat,Sun" or "Mon,Wed-Fri" (ranges may
+// wrap around, ex- "Fri-Mon").
+func parseWeekdays(txt string) ([7]bool, error) {
+	var ans [7]bool
+	for _, part := range strings.Split(txt, ",") {
+		var ends = strings.SplitN(part, "-", 2)
+		start, err := parseWeekday(ends[0])
+		if err != nil {
+			return ans, err
+		}
+		var end = start
+		if len(ends) > 1 {
+			if end, err = parseWeekday(ends[1]); err != nil {
+				return ans, err
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			ans[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return ans, nil
+}
+
+// Parse a time of day in minutes, as "15:04" (or "24:00").
+func parseMinutes(txt string) (int, error) {
+	if txt == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse(inputTimeLayout, txt)
 	if err != nil {
-		return lr, fmt.Errorf("limitBetween end time was invalid: %v", err)
+		return 0, fmt.Errorf("'%s' is not a time of day", txt)
 	}
-	lr.end = time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, time.Local)
+	return t.Hour()*60 + t.Minute(), nil
+}
 
-	// handle range spanning midnight
-	if lr.end.Before(lr.start) {
-		lr.end = lr.end.AddDate(0, 0, 1)
+// Parse an upload rate in B/s, as kbps ex- "2000", "2000kbps" or "2mbps",
+// or "unlimited" or "off".
+func parseLimitRate(txt string) (int64, error) {
+	var txt0 = strings.ToLower(strings.TrimSpace(txt))
+	switch txt0 {
+	case "unlimited", "none":
+		return rateUnlimited, nil
+	case "off", "pause":
+		return rateOff, nil
+	}
+	// kbit/s to B/s = 1000/8 = 125
+	var mult = 125.0
+	if strings.HasSuffix(txt0, "mbps") {
+		mult, txt0 = 125000, strings.TrimSuffix(txt0, "mbps")
+	}
+	txt0 = strings.TrimSuffix(txt0, "kbps")
+	n, err := strconv.ParseFloat(strings.TrimSpace(txt0), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("'%s' is not an upload rate", txt)
 	}
+	if n > 0 && n*mult < 1 {
+		return 1, nil
+	}
+	return int64(n * mult), nil
+}
 
-	return lr, nil
+// Parse an upload schedule, as windows "[days] [HH:MM-HH:MM][=rate]"
+// separated by ";" ex- "Mon-Fri 09:00-18:00=2000kbps; Sat,Sun=off".
+// Windows without days are every day, without times all day, and without
+// rate use the default rate.
+func parseLimitSchedule(txt string, rate int64) (limitSchedule, error) {
+	var ans limitSchedule
+	for _, part := range strings.Split(txt, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		var w = limitWindow{days: [7]bool{true, true, true, true, true, true, true}, rate: rate}
+		var err error
+		var spec = part
+		if i := strings.Index(part, "="); i >= 0 {
+			spec = part[:i]
+			if w.rate, err = parseLimitRate(part[i+1:]); err != nil {
+				return nil, err
+			}
+		}
+		var fields = strings.Fields(spec)
+		if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+			if w.days, err = parseWeekdays(fields[0]); err != nil {
+				return nil, err
+			}
+			fields = fields[1:]
+		}
+		if len(fields) > 1 {
+			return nil, fmt.Errorf("'%s' is not a schedule window", strings.TrimSpace(part))
+		}
+		if len(fields) == 1 {
+			var ends = strings.Split(fields[0], "-")
+			if len(ends) != 2 {
+				return nil, fmt.Errorf("'%s' should be 2 times separated by a hyphen", fields[0])
+			}
+			if w.start, err = parseMinutes(ends[0]); err != nil {
+				return nil, err
+			}
+			if w.end, err = parseMinutes(ends[1]); err != nil {
+				return nil, err
+			}
+			// "00:00-24:00" is all day
+			w.start, w.end = w.start%(24*60), w.end%(24*60)
+		}
+		ans = append(ans, w)
+	}
+	// windows of some days apply over those of every day
+	sort.SliceStable(ans, func(i, j int) bool {
+		return !ans[i].daily() && ans[j].daily()
+	})
+	return ans, nil
 }
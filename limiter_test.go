@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/porjo/go-flowrate/flowrate"
+)
+
+func TestParseLimitRate(t *testing.T) {
+	var tests = []struct {
+		txt  string
+		want int64
+	}{
+		{"2000", 250000},
+		{"2000kbps", 250000},
+		{"2mbps", 250000},
+		{"0.5 Mbps", 62500},
+		{"0.001", 1},
+		{"0", rateUnlimited},
+		{"unlimited", rateUnlimited},
+		{"off", rateOff},
+		{"Pause", rateOff},
+	}
+	for _, tt := range tests {
+		if got, err := parseLimitRate(tt.txt); err != nil || got != tt.want {
+			t.Errorf("parseLimitRate(%q) = %d, %v, want %d", tt.txt, got, err, tt.want)
+		}
+	}
+	for _, txt := range []string{"", "fast", "-1", "2gbps"} {
+		if _, err := parseLimitRate(txt); err == nil {
+			t.Errorf("parseLimitRate(%q) should fail", txt)
+		}
+	}
+}
+
+func TestParseLimitSchedule(t *testing.T) {
+	var tests = []struct {
+		txt  string
+		want limitSchedule
+	}{
+		{"10:00-14:00", limitSchedule{{allDays(), 600, 840, 125}}},
+		{"Sat,Sun=off", limitSchedule{{days(0, 6), 0, 0, rateOff}}},
+		{"00:00-24:00=unlimited", limitSchedule{{allDays(), 0, 0, rateUnlimited}}},
+		{"Fri-Mon 22:00-06:00=2mbps", limitSchedule{{days(5, 6, 0, 1), 1320, 360, 250000}}},
+		// windows of some days first, else in order
+		{"18:00-09:00=unlimited; Mon-Fri 09:00-18:00=2000kbps; ; Sat,Sun=off", limitSchedule{
+			{days(1, 2, 3, 4, 5), 540, 1080, 250000},
+			{days(0, 6), 0, 0, rateOff},
+			{allDays(), 1080, 540, rateUnlimited},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := parseLimitSchedule(tt.txt, 125)
+		if err != nil {
+			t.Errorf("parseLimitSchedule(%q): %v", tt.txt, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("parseLimitSchedule(%q) = %v, want %v", tt.txt, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("parseLimitSchedule(%q)[%d] = %v, want %v", tt.txt, i, got[i], tt.want[i])
+			}
+		}
+	}
+	for _, txt := range []string{"Mon-Fry=off", "09:00=off", "Mon 09:00-10:00 11:00-12:00", "25:00-26:00", "09:00-10:00=fast"} {
+		if _, err := parseLimitSchedule(txt, 0); err == nil {
+			t.Errorf("parseLimitSchedule(%q) should fail", txt)
+		}
+	}
+}
+
+func TestLimitScheduleRate(t *testing.T) {
+	s, err := parseLimitSchedule("Mon-Fri 09:00-18:00=2000kbps; 18:00-09:00=unlimited; Sat,Sun=off", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		now  string
+		want int64
+	}{
+		{"2024-06-03 09:00", 250000},        // Monday
+		{"2024-06-07 17:59", 250000},        // Friday
+		{"2024-06-07 18:00", rateUnlimited}, // Friday evening
+		{"2024-06-04 08:59", rateUnlimited}, // Tuesday morning
+		{"2024-06-08 20:00", rateOff},       // Saturday evening
+		{"2024-06-09 12:00", rateOff},       // Sunday
+		{"2024-06-10 03:00", rateUnlimited}, // Monday night
+	}
+	for _, tt := range tests {
+		now, _ := time.ParseInLocation("2006-01-02 15:04", tt.now, time.UTC)
+		if got := s.rate(now); got != tt.want {
+			t.Errorf("rate(%s) = %d, want %d", tt.now, got, tt.want)
+		}
+	}
+	// windows of previous day spanning midnight
+	s, _ = parseLimitSchedule("Fri 22:00-06:00=off", 0)
+	sat, _ := time.ParseInLocation("2006-01-02 15:04", "2024-06-08 05:00", time.UTC)
+	if got := s.rate(sat); got != rateOff {
+		t.Errorf("rate(Sat 05:00) = %d, want off", got)
+	}
+	if got := s.rate(sat.Add(time.Hour)); got != rateUnlimited {
+		t.Errorf("rate(Sat 06:00) = %d, want unlimited", got)
+	}
+}
+
+func TestLimitScheduleUntil(t *testing.T) {
+	s, _ := parseLimitSchedule("Sat,Sun=off; 18:00-09:00=off", 0)
+	var fri = time.Date(2024, 6, 7, 19, 0, 0, 0, time.UTC)
+	if got, want := s.until(fri), time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("until(Fri 19:00) = %v, want %v", got, want)
+	}
+	s, _ = parseLimitSchedule("=off", 0)
+	if s.rate(fri) != rateOff {
+		t.Fatalf("rate of always off = %d", s.rate(fri))
+	}
+	if got := s.until(fri); !got.IsZero() {
+		t.Errorf("until of always off = %v, want zero", got)
+	}
+	// local clock times, across a DST change
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	s, _ = parseLimitSchedule("22:00-06:00=off", 0)
+	var sat = time.Date(2024, 3, 9, 23, 0, 0, 0, loc)
+	var want = time.Date(2024, 3, 10, 6, 0, 0, 0, loc)
+	if got := s.until(sat); !got.Equal(want) || got.Sub(sat) != 6*time.Hour {
+		t.Errorf("until(Sat 23:00 before DST) = %v, want %v", got, want)
+	}
+}
+
+// Get a limit checker of a schedule, on a clock starting at a time.
+func newTestChecker(ctx context.Context, txt string, start time.Time) *limitChecker {
+	s, _ := parseLimitSchedule(txt, 0)
+	var b = newBandwidth(s)
+	var real = time.Now()
+	b.now = func() time.Time { return start.Add(time.Since(real)) }
+	var r = flowrate.NewReader(bytes.NewReader(make([]byte, 100)), 0)
+	b.add(r, 1)
+	return &limitChecker{b, r, ctx}
+}
+
+func TestLimitCheckerPause(t *testing.T) {
+	// 300ms before the end of an off window
+	var lc = newTestChecker(context.Background(), "10:00-10:01=off", time.Date(2024, 6, 3, 10, 0, 59, 700e6, time.UTC))
+	var start = time.Now()
+	n, err := lc.Read(make([]byte, 100))
+	if err != nil || n != 100 {
+		t.Fatalf("Read = %d, %v", n, err)
+	}
+	if d := time.Since(start); d < 250*time.Millisecond {
+		t.Errorf("Read returned after %v, should stall till window end", d)
+	}
+	// no stall outside the window
+	start = time.Now()
+	if _, err = lc.Read(make([]byte, 100)); time.Since(start) > 100*time.Millisecond {
+		t.Errorf("Read stalled %v outside off window", time.Since(start))
+	}
+}
+
+func TestLimitCheckerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var lc = newTestChecker(ctx, "=off", time.Now())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := lc.Read(make([]byte, 100)); err != context.Canceled {
+		t.Errorf("Read = %v, want canceled", err)
+	}
+	// resumed by control
+	lc = newTestChecker(context.Background(), "=off", time.Now())
+	time.AfterFunc(100*time.Millisecond, func() { lc.bw.setRate(rateUnlimited, false) })
+	if n, err := lc.Read(make([]byte, 100)); err != nil || n != 100 {
+		t.Errorf("Read = %d, %v after control resume", n, err)
+	}
+}
+
+func allDays() [7]bool {
+	return [7]bool{true, true, true, true, true, true, true}
+}
+
+func days(d ...time.Weekday) [7]bool {
+	var ans [7]bool
+	for _, i := range d {
+		ans[i] = true
+	}
+	return ans
+}
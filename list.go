@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Parts fetched to list videos.
+var listParts = []string{"snippet", "status"}
+
+// CSV columns of listed videos, which can be edited and given to update.
+var listColumns = []string{"id", "title", "privacyStatus", "publishAt", "tags", "publishedAt", "url"}
+
+// listItem is a video of the channel.
+type listItem struct {
+	Event         string   `json:"event"`
+	Id            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	PrivacyStatus string   `json:"privacyStatus"`
+	PublishedAt   string   `json:"publishedAt"`
+	PublishAt     string   `json:"publishAt,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// listFilter selects videos to list, empty fields match all.
+type listFilter struct {
+	Title   *regexp.Regexp
+	Privacy []string
+	Tag     string
+	After   time.Time
+	Before  time.Time
+}
+
+// Get filter of videos to list, as set by flags.
+func getListFilter(now time.Time) (listFilter, error) {
+	var ans = listFilter{Tag: f.FilterTag}
+	var err error
+	if f.FilterTitle != "" {
+		if ans.Title, err = regexp.Compile("(?i)" + f.FilterTitle); err != nil {
+			return ans, fmt.Errorf("Invalid title filter: %v", err)
+		}
+	}
+	for _, p := range strings.Split(f.FilterPrivacy, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ans.Privacy = append(ans.Privacy, strings.ToLower(p))
+		}
+	}
+	if f.FilterAfter != "" {
+		if ans.After, err = parsePublishAt(f.FilterAfter, now); err != nil {
+			return ans, fmt.Errorf("Invalid after filter: %v", err)
+		}
+	}
+	if f.FilterBefore != "" {
+		if ans.Before, err = parsePublishAt(f.FilterBefore, now); err != nil {
+			return ans, fmt.Errorf("Invalid before filter: %v", err)
+		}
+	}
+	return ans, nil
+}
+
+// Check if a video matches the filter.
+func (l *listFilter) match(y *youtube.Video) bool {
+	var s, st = y.Snippet, y.Status
+	if l.Title != nil && !l.Title.MatchString(s.Title) {
+		return false
+	}
+	if len(l.Privacy) > 0 && !stringsIncludes(l.Privacy, st.PrivacyStatus) {
+		return false
+	}
+	if l.Tag != "" && !stringsIncludesFold(s.Tags, l.Tag) {
+		return false
+	}
+	if !l.After.IsZero() || !l.Before.IsZero() {
+		t, err := time.Parse(time.RFC3339, s.PublishedAt)
+		if err != nil || (!l.After.IsZero() && t.Before(l.After)) || (!l.Before.IsZero() && !t.Before(l.Before)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newListItem(y *youtube.Video) *listItem {
+	return &listItem{
+		Event:         "video",
+		Id:            y.Id,
+		URL:           videoURL(y.Id),
+		Title:         y.Snippet.Title,
+		PrivacyStatus: y.Status.PrivacyStatus,
+		PublishedAt:   y.Snippet.PublishedAt,
+		PublishAt:     y.Status.PublishAt,
+		Tags:          y.Snippet.Tags,
+	}
+}
+
+// Get videos of the channel matching the filter, newest first.
+func listVideos(srv *youtube.Service, l listFilter) ([]*listItem, error) {
+	vids, err := uploader.ListUploads(srv, listParts)
+	if err != nil {
+		return nil, err
+	}
+	var ans = []*listItem{}
+	for _, y := range vids {
+		if y.Snippet != nil && y.Status != nil && l.match(y) {
+			ans = append(ans, newListItem(y))
+		}
+	}
+	return ans, nil
+}
+
+// Write listed videos as a table.
+func writeListTable(items []*listItem) {
+	var w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID\tPUBLISHED\tPRIVACY\tTITLE\n")
+	for _, itm := range items {
+		var sta = itm.PrivacyStatus
+		if itm.PublishAt != "" {
+			sta += " (at " + itm.PublishAt + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", itm.Id, itm.PublishedAt, sta, itm.Title)
+	}
+	w.Flush()
+}
+
+// Write listed videos as CSV, with list columns.
+func writeListCSV(items []*listItem) error {
+	var w = csv.NewWriter(os.Stdout)
+	w.Write(listColumns)
+	for _, itm := range items {
+		w.Write([]string{itm.Id, itm.Title, itm.PrivacyStatus, itm.PublishAt, strings.Join(itm.Tags, ","), itm.PublishedAt, itm.URL})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Get requests to list videos of the channel.
+func dryRunList() []*dryRequest {
+	c := newDryRequest("GET", "channels", []string{"contentDetails"}, nil)
+	c.Params = map[string]string{"mine": "true"}
+	i := newDryRequest("GET", "playlistItems", []string{"contentDetails"}, nil)
+	i.Params = map[string]string{"playlistId": "<uploads playlist id>", "maxResults": "50"}
+	i.Note = "once per 50 videos"
+	v := newDryRequest("GET", "videos", listParts, nil)
+	v.Params = map[string]string{"id": "<ids of listed page>"}
+	v.Note = "once per 50 videos"
+	return []*dryRequest{c, i, v}
+}
+
+// List videos of the channel, as "list [filters]".
+func onList(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	getFlags()
+	uploader.Logf = logf
+	l, err := getListFilter(time.Now())
+	if err != nil {
+		printf("%v\n", err)
+		os.Exit(exitError)
+	}
+	if f.DryRun {
+		printDryRun(dryRunList())
+		return
+	}
+	items, err := listVideos(getAPIClient().service, l)
+	if err != nil {
+		if outputIsJSON() {
+			writeOutput(&jobResult{Event: "result", Error: newOutputError(err)})
+			os.Exit(exitCode(err))
+		}
+		fatal(err)
+	}
+	switch f.Output {
+	case outputJSON:
+		writeOutput(items)
+	case outputJSONL:
+		for _, itm := range items {
+			writeOutput(itm)
+		}
+	case outputCSV:
+		err = writeListCSV(items)
+	default:
+		writeListTable(items)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
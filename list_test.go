@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Upload videos titled "ep N", odd ones private with tag "odd".
+func uploadTestVideos(t *testing.T, api *apiClient, n int) []string {
+	var ids []string
+	for i := 1; i <= n; i++ {
+		var v = &youtube.Video{Snippet: &youtube.VideoSnippet{Title: fmt.Sprintf("ep %d", i)}, Status: &youtube.VideoStatus{PrivacyStatus: "public"}}
+		if i%2 == 1 {
+			v.Snippet.Tags = []string{"Odd"}
+			v.Status.PrivacyStatus = "private"
+		}
+		v, err := uploader.UploadVideo(api.service, bytes.NewReader([]byte("video")), v, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, v.Id)
+	}
+	return ids
+}
+
+func TestListVideos(t *testing.T) {
+	_, api, _ := newTestAPI(t)
+	// more than a page of 50
+	var ids = uploadTestVideos(t, api, 55)
+	items, err := listVideos(api.service, listFilter{})
+	if err != nil || len(items) != 55 {
+		t.Fatalf("listVideos = %d items, %v, want 55", len(items), err)
+	}
+	if items[0].Id != ids[54] || items[54].Id != ids[0] || items[0].URL != videoURL(ids[54]) {
+		t.Errorf("first, last = %s, %s, want newest first", items[0].Id, items[54].Id)
+	}
+	var tests = []struct {
+		flags [3]string
+		want  int
+	}{
+		{[3]string{"^EP 5\\d$", "", ""}, 6},
+		{[3]string{"", "private", ""}, 28},
+		{[3]string{"", "public, Unlisted", ""}, 27},
+		{[3]string{"", "", "odd"}, 28},
+		{[3]string{"^ep 5", "public", "odd"}, 0},
+		{[3]string{"^ep 5", "private", "odd"}, 4},
+	}
+	for _, tt := range tests {
+		f.FilterTitle, f.FilterPrivacy, f.FilterTag = tt.flags[0], tt.flags[1], tt.flags[2]
+		l, err := getListFilter(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if items, err = listVideos(api.service, l); err != nil || len(items) != tt.want {
+			t.Errorf("listVideos(%q) = %d items, %v, want %d", tt.flags, len(items), err, tt.want)
+		}
+	}
+}
+
+func TestListFilterTime(t *testing.T) {
+	f = appFlags{FilterAfter: "-30d", FilterBefore: "2024-06-01T00:00:00Z"}
+	var now = time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	l, err := getListFilter(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for txt, want := range map[string]bool{
+		"2024-05-20T10:00:00Z": true,
+		"2024-05-11T00:00:00Z": true,
+		"2024-05-10T23:59:59Z": false,
+		"2024-06-01T00:00:00Z": false,
+		"":                     false,
+	} {
+		var y = &youtube.Video{Snippet: &youtube.VideoSnippet{PublishedAt: txt}, Status: &youtube.VideoStatus{}}
+		if got := l.match(y); got != want {
+			t.Errorf("match(%q) = %v, want %v", txt, got, want)
+		}
+	}
+	for _, g := range []appFlags{{FilterTitle: "ep ("}, {FilterAfter: "soon"}, {FilterBefore: "later"}} {
+		f = g
+		if _, err = getListFilter(now); err == nil {
+			t.Errorf("getListFilter(%+v) should fail", g)
+		}
+	}
+}
@@ -2,26 +2,31 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	youtube "google.golang.org/api/youtube/v3"
 )
 
+func printf(msg string, a ...interface{}) {
+	fmt.Fprintf(textOut, msg, a...)
+}
+
 func printfString(msg string, val string) {
 	if val != "" {
-		fmt.Printf(msg, val)
+		printf(msg, val)
 	}
 }
 
 func logf(msg string, a ...interface{}) {
 	if f.Log {
-		fmt.Printf(msg, a...)
+		printf(msg, a...)
 	}
 }
 
 func logfString(msg string, val string) {
 	if f.Log && val != "" {
-		fmt.Printf(msg, val)
+		printf(msg, val)
 	}
 }
 
@@ -75,11 +80,17 @@ func logUploadFlags(y *youtube.Video) {
 	if !f.Log {
 		return
 	}
-	fmt.Printf("%v\n", y.Snippet.Title)
-	fmt.Printf(" - %v\n", videoBasics(y))
+	printf("%v\n", y.Snippet.Title)
+	printf(" - %v\n", videoBasics(y))
 	printfString(" @%v\n", videoRecordingDetails(y.RecordingDetails))
-	fmt.Printf(" - %v\n", shortString(strings.Join(y.Snippet.Tags, ","), 60))
+	printf(" - %v\n", shortString(strings.Join(y.Snippet.Tags, ","), 60))
+	if len(y.Localizations) > 0 {
+		var langs []string
+		for k := range y.Localizations {
+			langs = append(langs, k)
+		}
+		sort.Strings(langs)
+		printf(" - localized: %v\n", strings.Join(langs, ","))
+	}
 	printfString("\n%v\n\n", shortString(y.Snippet.Description, 256))
-	printfString(" -> id: %v\n", f.ClientID)
-	printfString(" -> token: %v\n\n", f.ClientToken)
 }
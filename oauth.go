@@ -122,6 +122,11 @@ func readConfig(scopes []string) (*oauth2.Config, error) {
 		},
 		RedirectURL: cfg2.RedirectURIs[0],
 	}
+	// custom API endpoint also handles OAuth
+	if f.ApiEndpoint != "" {
+		oCfg.Endpoint.AuthURL = endpointURL("o/oauth2/auth")
+		oCfg.Endpoint.TokenURL = endpointURL("token")
+	}
 	return oCfg, nil
 }
 
@@ -185,9 +190,9 @@ func buildOAuthHTTPClient(ctx context.Context, scopes []string) (*http.Client, e
 		var cbs CallbackStatus
 
 		if f.AuthHeadless {
-			fmt.Printf("Visit the URL for the auth dialog: %v\n", url)
+			printf("Visit the URL for the auth dialog: %v\n", url)
 
-			fmt.Printf("Enter authorisation code here: ")
+			printf("Enter authorisation code here: ")
 			// FIXME: how to check state?
 			cbs.state = randState
 			if _, err := fmt.Scanln(&cbs.code); err != nil {
@@ -196,10 +201,10 @@ func buildOAuthHTTPClient(ctx context.Context, scopes []string) (*http.Client, e
 		} else {
 			err = openURL(url)
 			if err != nil {
-				fmt.Println("Visit the URL below to get a code.",
+				fmt.Fprintln(textOut, "Visit the URL below to get a code.",
 					" This program will pause until the site is visted.")
 			} else {
-				fmt.Println("Your browser has been opened to an authorization URL.",
+				fmt.Fprintln(textOut, "Your browser has been opened to an authorization URL.",
 					" This program will resume once authorization has been provided.")
 			}
 
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Output formats
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+	outputCSV   = "csv"
+)
+
+// Names of exit codes, used as error kind.
+var exitNames = map[int]string{
+	exitError:            "error",
+	exitFile:             "file",
+	exitQuotaExceeded:    "quotaExceeded",
+	exitAuthExpired:      "authExpired",
+	exitVideoNotFound:    "videoNotFound",
+	exitPlaylistNotFound: "playlistNotFound",
+	exitSessionExpired:   "sessionExpired",
+	exitAPIError:         "apiError",
+	exitInvalidMeta:      "invalidMetadata",
+	exitVideoChanged:     "videoChanged",
+	exitCanceled:         "canceled",
+}
+
+// textOut gets human readable messages, stderr when output is JSON.
+var textOut io.Writer = os.Stdout
+
+// outputError is an error, with its exit code.
+type outputError struct {
+	Code    int    `json:"code"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// captionResult is a caption track uploaded (or updated).
+type captionResult struct {
+	File     string `json:"file"`
+	Language string `json:"language"`
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+}
+
+// playlistResult is a playlist a video was added to, by id or title.
+type playlistResult struct {
+	Id    string `json:"id,omitempty"`
+	Title string `json:"title,omitempty"`
+}
+
+// fieldChange is a video field changed by an update, values as JSON.
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// jobResult is the result of a video job.
+type jobResult struct {
+	Event     string           `json:"event"`
+	Row       int              `json:"row,omitempty"`
+	File      string           `json:"file,omitempty"`
+	Id        string           `json:"id,omitempty"`
+	URL       string           `json:"url,omitempty"`
+	Action    string           `json:"action,omitempty"`
+	Hash      string           `json:"hash,omitempty"`
+	Metadata  *youtube.Video   `json:"metadata,omitempty"`
+	Thumbnail string           `json:"thumbnail,omitempty"`
+	Captions  []captionResult  `json:"captions,omitempty"`
+	Playlists []playlistResult `json:"playlists,omitempty"`
+	Warnings  []string         `json:"warnings,omitempty"`
+	Changes   []fieldChange    `json:"changes,omitempty"`
+	Started   time.Time        `json:"started"`
+	// elapsed times, in seconds
+	UploadTime float64      `json:"uploadTime,omitempty"`
+	TotalTime  float64      `json:"totalTime"`
+	Error      *outputError `json:"error,omitempty"`
+}
+
+// progressEvent is the upload progress of a video, or of all uploads
+// (without file) when there are several.
+type progressEvent struct {
+	Event   string  `json:"event"`
+	File    string  `json:"file,omitempty"`
+	Streams int     `json:"streams,omitempty"`
+	Bytes   int64   `json:"bytes"`
+	Total   int64   `json:"total"`
+	Rate    int64   `json:"rate"`
+	ETA     float64 `json:"eta"`
+}
+
+// searchResult is the video ids matching a title.
+type searchResult struct {
+	Event string       `json:"event"`
+	Title string       `json:"title"`
+	Ids   []string     `json:"ids"`
+	Error *outputError `json:"error,omitempty"`
+}
+
+func newOutputError(err error) *outputError {
+	if err == nil {
+		return nil
+	}
+	var code = exitCode(err)
+	return &outputError{Code: code, Kind: exitNames[code], Message: err.Error()}
+}
+
+func videoURL(id string) string {
+	return "https://www.youtube.com/watch?v=" + id
+}
+
+// Set id, error and total time of a job result.
+func (r *jobResult) finish(id string, err error) {
+	r.Id = id
+	if id != "" && r.URL == "" {
+		r.URL = videoURL(id)
+	}
+	r.Error = newOutputError(err)
+	if !r.Started.IsZero() {
+		r.TotalTime = time.Since(r.Started).Seconds()
+	}
+}
+
+// Check if output is JSON.
+func outputIsJSON() bool {
+	return f.Output == outputJSON || f.Output == outputJSONL
+}
+
+// Set output format, as set by flags.
+func setOutput() {
+	switch f.Output {
+	case "", outputText:
+	case outputJSON, outputJSONL, outputCSV:
+		textOut = os.Stderr
+	default:
+		fmt.Printf("Invalid output format '%s' (text, json, jsonl, csv)\n", f.Output)
+		os.Exit(exitError)
+	}
+}
+
+// Objects are written one at a time, by all workers.
+var outputMu sync.Mutex
+
+// Write an object as JSON, indented unless output is JSON lines.
+func writeOutput(v interface{}) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	var enc = json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	if f.Output != outputJSONL {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+	}
+}
+
+// Write progress of an upload, as JSON line.
+func writeProgress(st *progressStream) {
+	s := st.status
+	writeOutput(&progressEvent{
+		Event: "progress",
+		File:  st.nam,
+		Bytes: s.Bytes,
+		Total: st.filesize,
+		Rate:  s.CurRate,
+		ETA:   s.TimeRem.Round(time.Second).Seconds(),
+	})
+}
+
+// Write total progress of several uploads, as JSON line.
+func writeProgressTotal(streams []*progressStream) {
+	var ans = &progressEvent{Event: "progress", Streams: len(streams)}
+	for _, st := range streams {
+		ans.Bytes += st.status.Bytes
+		ans.Total += st.filesize
+		ans.Rate += st.status.CurRate
+	}
+	if ans.Rate > 0 && ans.Total > ans.Bytes {
+		ans.ETA = time.Duration(float64(ans.Total-ans.Bytes) / float64(ans.Rate) * float64(time.Second)).Round(time.Second).Seconds()
+	}
+	writeOutput(ans)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunBatchOutput(t *testing.T) {
+	_, api, dir := newTestAPI(t)
+	writeTestFile(t, dir, "ep01.mp4", []byte("video 1"))
+	var pth = writeTestFile(t, dir, "shows.csv", []byte("video,title\nep01.mp4,Episode 1\nmissing.mp4,Episode 2\n"))
+	f.Output = outputJSON
+	setOutput()
+	var out = captureStdout(t, func() { runBatch(api, pth) })
+	var res []jobResult
+	if err := json.Unmarshal([]byte(out), &res); err != nil || len(res) != 2 {
+		t.Fatalf("json output %q: %v", out, err)
+	}
+	var r = res[0]
+	if r.Event != "result" || r.Row != 1 || r.Action != "uploaded" || r.URL != videoURL(r.Id) || r.Error != nil {
+		t.Errorf("row 1 = %+v", r)
+	}
+	if r.Metadata == nil || r.Metadata.Snippet.Title != "Episode 1" || r.Hash == "" {
+		t.Errorf("row 1 metadata, hash = %+v, %q", r.Metadata, r.Hash)
+	}
+	if e := res[1].Error; res[1].Row != 2 || res[1].Id != "" || e == nil || e.Code != exitFile || e.Kind != "file" {
+		t.Errorf("row 2 = %+v, error %+v", res[1], e)
+	}
+	// a line per row, as rows finish; ledger has row 1 now
+	f.Output = outputJSONL
+	out = captureStdout(t, func() { runBatch(api, pth) })
+	var lines = strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("jsonl output = %q, want 2 lines", out)
+	}
+	for _, l := range lines {
+		if err := json.Unmarshal([]byte(l), &r); err != nil {
+			t.Fatalf("jsonl line %q: %v", l, err)
+		}
+		if r.Row == 1 && (r.Action != "unchanged" || r.Id != res[0].Id) {
+			t.Errorf("row 1 = %s %s, want unchanged %s", r.Id, r.Action, res[0].Id)
+		}
+	}
+}
+
+func TestWriteListCSV(t *testing.T) {
+	var items = []*listItem{
+		{Id: "a", URL: videoURL("a"), Title: "Ep 1, \"pilot\"", PrivacyStatus: "private", PublishAt: "2024-06-01T10:00:00Z", Tags: []string{"show", "pilot"}, PublishedAt: "2024-05-01T10:00:00Z"},
+		{Id: "b", URL: videoURL("b"), Title: "Ep 2", PrivacyStatus: "public"},
+	}
+	var out = captureStdout(t, func() {
+		if err := writeListCSV(items); err != nil {
+			t.Error(err)
+		}
+	})
+	rows, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil || len(rows) != 3 {
+		t.Fatalf("csv output %q: %v", out, err)
+	}
+	if !reflect.DeepEqual(rows[0], listColumns) {
+		t.Errorf("header = %q, want %q", rows[0], listColumns)
+	}
+	var want = []string{"a", "Ep 1, \"pilot\"", "private", "2024-06-01T10:00:00Z", "show,pilot", "2024-05-01T10:00:00Z", videoURL("a")}
+	if !reflect.DeepEqual(rows[1], want) {
+		t.Errorf("row = %q, want %q", rows[1], want)
+	}
+	// listed rows can be given to update
+	m, err := readBatchManifest(writeTestFile(t, t.TempDir(), "list.csv", []byte(out)))
+	if err != nil || len(m) != 2 {
+		t.Fatalf("readBatchManifest = %v, %v", m, err)
+	}
+	job, err := parseBatchRow("", m[0])
+	if err != nil || job.Id != "a" || job.Meta.Title != "Ep 1, \"pilot\"" || !reflect.DeepEqual(job.Meta.Tags, []string{"show", "pilot"}) {
+		t.Errorf("row 1 = %+v, %v", job, err)
+	}
+}
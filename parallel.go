@@ -0,0 +1,50 @@
+package main
+
+import "sync"
+
+// Get number of workers for n videos, one for dry run and validate.
+func getParallel(n int) int {
+	var ans = parseInt(f.Parallel, 1)
+	if f.DryRun || validateOnly || ans < 1 {
+		ans = 1
+	}
+	if n > 0 && ans > n {
+		ans = n
+	}
+	return ans
+}
+
+// Get API clients of workers, the first being api. Each has its own
+// transport, to track its upload, and all share upload rate and quota.
+func workerClients(api *apiClient, n int) []*apiClient {
+	var ans = []*apiClient{api}
+	for i := 1; i < n; i++ {
+		if api == nil {
+			ans = append(ans, nil)
+		} else {
+			ans = append(ans, getAPIClient())
+		}
+	}
+	return ans
+}
+
+// Run a function for indexes 0..n-1, with workers taking the next index
+// when done. Returns once all are done.
+func runWorkers(api *apiClient, n int, fn func(api *apiClient, i int)) {
+	var next = make(chan int)
+	var wg sync.WaitGroup
+	for _, a := range workerClients(api, getParallel(n)) {
+		wg.Add(1)
+		go func(a *apiClient) {
+			defer wg.Done()
+			for i := range next {
+				fn(a, i)
+			}
+		}(a)
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
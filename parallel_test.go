@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetParallel(t *testing.T) {
+	var tests = []struct {
+		parallel string
+		dryRun   bool
+		n        int
+		want     int
+	}{
+		{"", false, 5, 1},
+		{"3", false, 5, 3},
+		{"3", false, 2, 2},
+		{"3", false, 0, 3},
+		{"0", false, 5, 1},
+		{"many", false, 5, 1},
+		{"3", true, 5, 1},
+	}
+	for _, tt := range tests {
+		f = appFlags{Parallel: tt.parallel, DryRun: tt.dryRun}
+		if got := getParallel(tt.n); got != tt.want {
+			t.Errorf("getParallel(%d) with -pa %q, dry run %v = %d, want %d", tt.n, tt.parallel, tt.dryRun, got, tt.want)
+		}
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	_, api, _ := newTestAPI(t)
+	f.Parallel = "3"
+	var mu sync.Mutex
+	var done = map[int]int{}
+	var clients = map[*apiClient]bool{}
+	var running, most int
+	runWorkers(api, 10, func(a *apiClient, i int) {
+		mu.Lock()
+		done[i]++
+		clients[a] = true
+		if running++; running > most {
+			most = running
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	})
+	if len(done) != 10 {
+		t.Errorf("ran %d indexes, want 10", len(done))
+	}
+	for i, n := range done {
+		if n != 1 {
+			t.Errorf("index %d ran %d times", i, n)
+		}
+	}
+	if most != 3 || len(clients) != 3 || !clients[api] {
+		t.Errorf("%d running at most, with %d clients, want 3 including api", most, len(clients))
+	}
+	// each worker tracks its own upload, sharing one rate
+	var transports = map[*limitTransport]bool{}
+	for a := range clients {
+		transports[a.transport] = true
+		if a.transport.bw != api.transport.bw {
+			t.Errorf("worker has its own bandwidth")
+		}
+	}
+	if len(transports) != 3 {
+		t.Errorf("%d transports, want 3", len(transports))
+	}
+}
+
+func TestRunBatchParallel(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var csv = "video,title\n"
+	for i := 1; i <= 6; i++ {
+		writeTestFile(t, dir, fmt.Sprintf("ep%d.mp4", i), []byte(fmt.Sprintf("video %d", i)))
+		csv += fmt.Sprintf("ep%d.mp4,Episode %d\n", i, i)
+	}
+	f.Parallel = "3"
+	if code := runBatch(api, writeTestFile(t, dir, "shows.csv", []byte(csv))); code != exitOK {
+		t.Fatalf("runBatch = %d", code)
+	}
+	var titles = map[string]bool{}
+	for _, v := range fake.Videos() {
+		titles[v.Snippet.Title] = true
+	}
+	if len(titles) != 6 || !titles["Episode 6"] {
+		t.Errorf("uploaded %v, want 6 episodes", titles)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Container formats expected of video file extensions.
+var videoExtFormats = map[string]string{
+	".mp4": "mp4", ".m4v": "mp4", ".mov": "mp4", ".3gp": "mp4",
+	".mkv": "matroska", ".webm": "webm",
+}
+
+// Get warnings for a video file, likely to be rejected.
+func probeWarnings(v templateFile) []string {
+	var ans []string
+	var ext = strings.ToLower(v.Ext)
+	if !videoExts[ext] {
+		ans = append(ans, fmt.Sprintf("unsupported container '%s'", ext))
+	}
+	if strings.HasPrefix(v.Path, "http") {
+		return ans
+	}
+	if exp, ok := videoExtFormats[ext]; ok && v.Format != exp && !(exp == "webm" && v.Format == "matroska") {
+		if v.Format == "" {
+			ans = append(ans, fmt.Sprintf("not a valid %s file", exp))
+		} else {
+			ans = append(ans, fmt.Sprintf("%s file with '%s' extension", v.Format, ext))
+		}
+	}
+	if v.Format != "mp4" && v.Format != "matroska" && v.Format != "webm" {
+		return ans
+	}
+	if v.Duration <= 0 {
+		ans = append(ans, "zero duration")
+	}
+	if v.VideoCodec == "" {
+		ans = append(ans, "no video track")
+	}
+	return ans
+}
+
+// Fill recording date and location from media info, if not set.
+func applyMediaInfo(y *youtube.Video, info uploader.MediaInfo) {
+	if y.RecordingDetails.RecordingDate == "" && !info.Created.IsZero() {
+		y.RecordingDetails.RecordingDate = info.Created.UTC().Format(ytDateLayout)
+	}
+	if y.RecordingDetails.Location == nil && info.Location != nil {
+		y.RecordingDetails.Location = info.Location
+	}
+}
+
+// Log media info of a video.
+func logMediaInfo(info uploader.MediaInfo) {
+	if info.Format == "" {
+		return
+	}
+	logf(" - %s, %v, %dx%d, %s/%s\n", info.Format, info.Duration.Round(time.Second), info.Width, info.Height, info.VideoCodec, info.AudioCodec)
+}
@@ -3,33 +3,159 @@ package main
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/porjo/go-flowrate/flowrate"
 )
 
-// Progress tracks upload progress
-func Progress(quitChan chanChan, transport *limitTransport, filesize int64) {
+// progressTracker tracks uploads in progress, and shows their progress in
+// one display for all.
+type progressTracker struct {
+	mu       sync.Mutex
+	streams  []*progressStream
+	quitChan chanChan
+}
+
+// progressStream is an upload in progress.
+type progressStream struct {
+	nam       string
+	transport *limitTransport
+	// status at last tick, if sending
+	status   flowrate.Status
+	filesize int64
+	sending  bool
+}
+
+// Uploads in progress, of all workers.
+var uploadProgress = &progressTracker{}
+
+// Start tracking an upload, showing its progress as text or JSON lines.
+func (p *progressTracker) start(transport *limitTransport, nam string, show bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.streams = append(p.streams, &progressStream{nam: nam, transport: transport})
+	if show && p.quitChan == nil {
+		p.quitChan = make(chanChan)
+		go Progress(p.quitChan, p)
+	}
+}
+
+// Stop tracking progress of an upload, and wait for display to finish
+// if it was the last one.
+func (p *progressTracker) stop(transport *limitTransport) {
+	p.mu.Lock()
+	var quitChan chanChan
+	for i, s := range p.streams {
+		if s.transport == transport {
+			p.streams = append(p.streams[:i], p.streams[i+1:]...)
+			break
+		}
+	}
+	if len(p.streams) == 0 {
+		quitChan, p.quitChan = p.quitChan, nil
+	}
+	p.mu.Unlock()
+	stopProgress(quitChan)
+}
+
+// Get uploads in progress, with their status.
+func (p *progressTracker) all() []*progressStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ans []*progressStream
+	for _, s := range p.streams {
+		st, size, ok := s.transport.status()
+		ans = append(ans, &progressStream{s.nam, s.transport, st, size, ok})
+	}
+	return ans
+}
+
+// Get uploads being sent, with their status.
+func (p *progressTracker) active() []*progressStream {
+	var ans []*progressStream
+	for _, s := range p.all() {
+		if s.sending {
+			ans = append(ans, s)
+		}
+	}
+	return ans
+}
+
+// Cancel uploads of files matching a glob pattern (all if empty), and
+// get their names.
+func (p *progressTracker) cancel(pat string) []string {
+	var ans = []string{}
+	for _, s := range p.all() {
+		if pat == "" || fileMatch(pat, s.nam) {
+			s.transport.abort()
+			ans = append(ans, s.nam)
+		}
+	}
+	return ans
+}
+
+// Format a rate in B/s, as kbps or Mbps.
+func formatRate(rate int64) string {
+	var r = float32(rate)
+	if r >= 125000 {
+		return fmt.Sprintf("%8.2f Mbps", r/125000)
+	}
+	return fmt.Sprintf("%8.2f kbps", r/125)
+}
+
+// Progress tracks upload progress, as text or JSON lines. With several
+// uploads, each is shown with their total.
+func Progress(quitChan chanChan, p *progressTracker) {
 	ticker := time.Tick(time.Second)
 	var erase int
 	for {
 		select {
 		case <-ticker:
-			if transport.reader != nil {
-				s := transport.reader.Monitor.Status()
-				curRate := float32(s.CurRate)
-				var status string
-				if curRate >= 125000 {
-					status = fmt.Sprintf("Progress: %8.2f Mbps, %d / %d (%s) ETA %8s", curRate/125000, s.Bytes, filesize, s.Progress, s.TimeRem)
-				} else {
-					status = fmt.Sprintf("Progress: %8.2f kbps, %d / %d (%s) ETA %8s", curRate/125, s.Bytes, filesize, s.Progress, s.TimeRem)
+			var streams = p.active()
+			if len(streams) > 0 && f.Output == outputJSONL {
+				for _, s := range streams {
+					writeProgress(s)
+				}
+				if len(streams) > 1 {
+					writeProgressTotal(streams)
+				}
+			} else if len(streams) == 1 {
+				s := streams[0].status
+				filesize := streams[0].filesize
+				status := fmt.Sprintf("Progress: %s, %d / %d (%s) ETA %8s", formatRate(s.CurRate), s.Bytes, filesize, s.Progress, s.TimeRem)
+				fmt.Fprintf(textOut, "\r%s\r%s", strings.Repeat(" ", erase), status)
+				erase = len(status)
+			} else if len(streams) > 1 {
+				var rate, bytes, total int64
+				var parts []string
+				for _, st := range streams {
+					s := st.status
+					rate += s.CurRate
+					bytes += s.Bytes
+					total += st.filesize
+					parts = append(parts, fmt.Sprintf("%s %s", shortString(st.nam, 20), s.Progress))
 				}
-				fmt.Printf("\r%s\r%s", strings.Repeat(" ", erase), status)
+				status := fmt.Sprintf("Progress: %s, %d / %d [%s]", formatRate(rate), bytes, total, strings.Join(parts, ", "))
+				fmt.Fprintf(textOut, "\r%s\r%s", strings.Repeat(" ", erase), status)
 				erase = len(status)
 			}
 		case ch := <-quitChan:
 			// final newline
-			fmt.Println()
+			if f.Output != outputJSONL {
+				fmt.Fprintln(textOut)
+			}
 			close(ch)
 			return
 		}
 	}
 }
+
+// Stop progress tracking, and wait for it to finish.
+func stopProgress(quitChan chanChan) {
+	if quitChan != nil {
+		quit := make(chan struct{})
+		quitChan <- quit
+		<-quit
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Change publish time of a private video, keeping the rest of its status.
+func rescheduleVideo(srv *youtube.Service, id string, at string) (*youtube.Video, error) {
+	v, err := uploader.GetVideo(srv, id, []string{"status"})
+	if err != nil {
+		return nil, err
+	}
+	if v.Status.PrivacyStatus != "private" {
+		return nil, fmt.Errorf("Video %s is %s, only private videos can be scheduled", id, v.Status.PrivacyStatus)
+	}
+	v.Status.PublishAt = at
+	var h = &historyRecord{Op: historyUpdate, Started: time.Now(), Id: id}
+	v, err = uploader.UpdateVideoParts(srv, &youtube.Video{Id: id, Status: v.Status}, []string{"status"})
+	addHistory(h, err)
+	return v, err
+}
+
+// Get requests to reschedule a video.
+func dryRunReschedule(id string, at string) []*dryRequest {
+	r := newDryRequest("GET", "videos", []string{"status"}, nil)
+	r.Params = map[string]string{"id": id}
+	u := newDryRequest("PUT", "videos", []string{"status"}, &youtube.Video{
+		Id:     id,
+		Status: &youtube.VideoStatus{PrivacyStatus: "private", PublishAt: at},
+	})
+	u.Note = "other status fields are kept"
+	return []*dryRequest{r, u}
+}
+
+// Change publish time of videos, as "reschedule -opa <time> <id>...".
+func onReschedule(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	getFlags()
+	uploader.Logf = logf
+	var ids = flag.Args()
+	if f.Id != "" {
+		ids = append([]string{f.Id}, ids...)
+	}
+	if len(ids) == 0 {
+		printf("No video id to reschedule!\n")
+		os.Exit(exitError)
+	}
+	var now = time.Now()
+	t, err := parsePublishAt(f.PublishAt, now)
+	if err != nil {
+		fatal(&validationError{[]problem{{"publishAt", err.Error(), false}}})
+	}
+	if t.Before(now) {
+		fatal(&validationError{[]problem{{"publishAt", fmt.Sprintf("'%s' is in the past", f.PublishAt), false}}})
+	}
+	var at = t.Format(ytDateLayout)
+	printf("Publish at %s (%s)\n", at, f.PublishAt)
+	if f.DryRun {
+		var reqs []*dryRequest
+		for _, id := range ids {
+			reqs = append(reqs, dryRunReschedule(id, at)...)
+		}
+		printDryRun(reqs)
+		return
+	}
+	api := getAPIClient()
+	var code = exitOK
+	var out = []*jobResult{}
+	for _, id := range ids {
+		var res = &jobResult{Event: "result", Started: time.Now()}
+		v, err := rescheduleVideo(api.service, id, at)
+		if err == nil {
+			res.Action = "rescheduled"
+			res.Metadata = v
+			printf("Rescheduled %s\n", id)
+		} else {
+			printf("Failed %s: %v\n", id, err)
+			if code == exitOK {
+				code = exitCode(err)
+			}
+		}
+		res.finish(id, err)
+		out = append(out, res)
+		if f.Output == outputJSONL {
+			writeOutput(res)
+		}
+	}
+	if f.Output == outputJSON {
+		writeOutput(out)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Publish time layouts without timezone, local unless a timezone is given.
+var publishAtLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Relative time, as "+1d2h30m" or "-30d".
+var reRelative = regexp.MustCompile("^([+-])(?:(\\d+)w)?(?:(\\d+)d)?(.*)$")
+
+// Days relative to today, by name.
+var dayNames = map[string]int{"today": 0, "tomorrow": 1}
+
+// Get time of day, as "15:04" or "15:04:05", on a date.
+func parseClock(txt string, day time.Time) (time.Time, error) {
+	var t, err = time.Parse(inputTimeLayout, txt)
+	if err != nil {
+		t, err = time.Parse("15:04:05", txt)
+	}
+	if err != nil {
+		return t, fmt.Errorf("'%s' is not a time of day", txt)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, day.Location()), nil
+}
+
+// Get next date of a day name, as "tomorrow" or "monday" (not today).
+func parseDayName(txt string, now time.Time) (time.Time, bool) {
+	var txt0 = strings.ToLower(txt)
+	if n, ok := dayNames[txt0]; ok {
+		return now.AddDate(0, 0, n), true
+	}
+	for d := 1; d <= 7; d++ {
+		var day = now.AddDate(0, 0, d)
+		if strings.ToLower(day.Weekday().String()) == txt0 {
+			return day, true
+		}
+	}
+	return now, false
+}
+
+// Parse a relative time, as "+2h", "+1d" or "-30d".
+func parseRelative(txt string, now time.Time) (time.Time, error) {
+	var m = reRelative.FindStringSubmatch(txt)
+	var t = now
+	if len(txt) < 2 {
+		return t, fmt.Errorf("'%s' is not a relative time", txt)
+	}
+	var sign = 1
+	if m[1] == "-" {
+		sign = -1
+	}
+	if m[2] != "" {
+		w, _ := strconv.Atoi(m[2])
+		t = t.AddDate(0, 0, sign*7*w)
+	}
+	if m[3] != "" {
+		d, _ := strconv.Atoi(m[3])
+		t = t.AddDate(0, 0, sign*d)
+	}
+	if m[4] != "" {
+		d, err := time.ParseDuration(m[4])
+		if err != nil {
+			return t, fmt.Errorf("'%s' is not a relative time", txt)
+		}
+		t = t.Add(time.Duration(sign) * d)
+	}
+	return t, nil
+}
+
+// Parse a publish time, as RFC 3339, "+2h", "+1d", "-30d", "today 18:00",
+// "tomorrow 09:00", "friday 09:00" or "2006-01-02 15:04", optionally
+// followed by a timezone name ex- "Europe/Paris" (local by default).
+func parsePublishAt(txt string, now time.Time) (time.Time, error) {
+	txt = strings.TrimSpace(txt)
+	if t, err := time.Parse(time.RFC3339, txt); err == nil {
+		return t.UTC(), nil
+	}
+	if strings.HasPrefix(txt, "+") || strings.HasPrefix(txt, "-") {
+		t, err := parseRelative(txt, now)
+		return t.UTC(), err
+	}
+	var loc = time.Local
+	var fields = strings.Fields(txt)
+	if n := len(fields); n > 1 {
+		if l, err := time.LoadLocation(fields[n-1]); err == nil {
+			loc = l
+			fields = fields[:n-1]
+		}
+	}
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("'%s' is not a publish time", txt)
+	}
+	now = now.In(loc)
+	if day, ok := parseDayName(fields[0], now); ok {
+		var clock = "00:00"
+		if len(fields) > 1 {
+			clock = fields[1]
+		}
+		if len(fields) > 2 {
+			return time.Time{}, fmt.Errorf("'%s' is not a publish time", txt)
+		}
+		t, err := parseClock(clock, day)
+		return t.UTC(), err
+	}
+	var date = strings.Join(fields, " ")
+	for _, layout := range publishAtLayouts {
+		if t, err := time.ParseInLocation(layout, date, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("'%s' is not a publish time", txt)
+}
+
+// Normalize publish time of a video to UTC, and force it to be private if
+// in the future. A past time publishes a private video now, and is ignored
+// for others (kept with --strict, to be refused). An invalid time is kept,
+// and reported by validation.
+func applyPublishAt(s *youtube.VideoStatus, now time.Time) {
+	var txt = s.PublishAt
+	if txt == "" {
+		return
+	}
+	t, err := parsePublishAt(txt, now)
+	if err != nil {
+		return
+	}
+	s.PublishAt = t.Format(ytDateLayout)
+	if t.Before(now) && !f.Strict {
+		if s.PrivacyStatus == "private" {
+			printf("publishAt (%s) was in the past!? Publishing now instead...\n", txt)
+			s.PrivacyStatus = "public"
+		} else {
+			printf("publishAt (%s) was in the past, ignoring it for privacy status '%s'\n", txt, s.PrivacyStatus)
+		}
+		s.PublishAt = ""
+		return
+	}
+	if t.Before(now) {
+		return
+	}
+	if s.PrivacyStatus != "private" {
+		printf("publishAt is set, privacy status '%s' changed to 'private'\n", s.PrivacyStatus)
+		s.PrivacyStatus = "private"
+	}
+	if s.PublishAt != txt {
+		printf("Publish at %s (%s)\n", s.PublishAt, txt)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestParsePublishAt(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	// Friday noon, before DST starts on Sunday 2024-03-10
+	var now = time.Date(2024, 3, 8, 12, 0, 0, 0, ny)
+	var tests = []struct {
+		txt  string
+		want string
+	}{
+		{"2024-07-01T18:00:00+02:00", "2024-07-01T16:00:00Z"},
+		{"+2h", "2024-03-08T19:00:00Z"},
+		{"+1d2h30m", "2024-03-09T19:30:00Z"},
+		{"+1w", "2024-03-15T16:00:00Z"},
+		{"-30d", "2024-02-07T17:00:00Z"},
+		// relative days keep the local time, across DST
+		{"+2d", "2024-03-10T16:00:00Z"},
+		{"today 18:00 America/New_York", "2024-03-08T23:00:00Z"},
+		{"tomorrow 09:00 America/New_York", "2024-03-09T14:00:00Z"},
+		{"Sunday America/New_York", "2024-03-10T05:00:00Z"},
+		// next week day, not today, after DST starts
+		{"friday 09:00 America/New_York", "2024-03-15T13:00:00Z"},
+		{"monday 09:30:15 America/New_York", "2024-03-11T13:30:15Z"},
+		{"2024-07-01 18:00 Europe/Paris", "2024-07-01T16:00:00Z"},
+		{"2024-01-15T08:00 Asia/Tokyo", "2024-01-14T23:00:00Z"},
+		{"2024-12-25 UTC", "2024-12-25T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		got, err := parsePublishAt(tt.txt, now)
+		if err != nil {
+			t.Errorf("parsePublishAt(%q): %v", tt.txt, err)
+			continue
+		}
+		if s := got.Format(time.RFC3339); s != tt.want {
+			t.Errorf("parsePublishAt(%q) = %s, want %s", tt.txt, s, tt.want)
+		}
+	}
+	for _, txt := range []string{"", "soon", "+1x", "friday 09:00 later", "friday 25:00", "2024-13-01 Europe/Paris"} {
+		if _, err := parsePublishAt(txt, now); err == nil {
+			t.Errorf("parsePublishAt(%q) should fail", txt)
+		}
+	}
+}
+
+func TestApplyPublishAt(t *testing.T) {
+	var now = time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		privacy   string
+		publishAt string
+		strict    bool
+		// privacy status and publish time after
+		wantPrivacy string
+		wantAt      string
+	}{
+		{"private", "+1d", false, "private", "2024-03-09T12:00:00.000Z"},
+		{"public", "2024-03-09T12:00:00Z", false, "private", "2024-03-09T12:00:00.000Z"},
+		// past time publishes private videos now, others ignore it
+		{"private", "-1h", false, "public", ""},
+		{"public", "2024-03-01T12:00:00Z", false, "public", ""},
+		{"unlisted", "-1d", false, "unlisted", ""},
+		// kept with strict, to be refused
+		{"public", "-1h", true, "public", "2024-03-08T11:00:00.000Z"},
+		// invalid kept, to be reported
+		{"public", "soon", false, "public", "soon"},
+	}
+	for _, tt := range tests {
+		f = appFlags{Strict: tt.strict}
+		var s = &youtube.VideoStatus{PrivacyStatus: tt.privacy, PublishAt: tt.publishAt}
+		applyPublishAt(s, now)
+		if s.PrivacyStatus != tt.wantPrivacy || s.PublishAt != tt.wantAt {
+			t.Errorf("applyPublishAt(%s, %q, strict %v) = %s, %q, want %s, %q", tt.privacy, tt.publishAt, tt.strict, s.PrivacyStatus, s.PublishAt, tt.wantPrivacy, tt.wantAt)
+		}
+	}
+	f = appFlags{}
+}
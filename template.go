@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+// templateFile is a file, as seen by templates.
+type templateFile struct {
+	Path    string
+	Name    string
+	Base    string
+	Ext     string
+	Dir     string
+	Size    int64
+	ModTime time.Time
+	uploader.MediaInfo
+}
+
+// templateData is the data of title, description and tags templates.
+type templateData struct {
+	Meta      map[string]interface{}
+	File      templateFile
+	Thumbnail templateFile
+	Env       map[string]string
+	Now       time.Time
+}
+
+// Functions available in templates.
+var templateFuncs = template.FuncMap{
+	"default": func(def interface{}, val interface{}) interface{} {
+		if val == nil || fmt.Sprint(val) == "" {
+			return def
+		}
+		return val
+	},
+	"date": func(layout string, t interface{}) string {
+		switch v := t.(type) {
+		case time.Time:
+			if v.IsZero() {
+				return ""
+			}
+			return v.Format(layout)
+		case string:
+			if d, err := time.Parse(time.RFC3339, v); err == nil {
+				return d.Format(layout)
+			}
+			if d, err := time.Parse(inputDateLayout, v); err == nil {
+				return d.Format(layout)
+			}
+			return v
+		}
+		return ""
+	},
+	"clock": func(d time.Duration) string {
+		d = d.Round(time.Second)
+		if d >= time.Hour {
+			return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+		}
+		return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+	},
+	"env":       os.Getenv,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"title":     titleCase,
+	"trim":      strings.TrimSpace,
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":  func(sub, s string) bool { return strings.Contains(s, sub) },
+	"hasPrefix": func(pre, s string) bool { return strings.HasPrefix(s, pre) },
+	"hasSuffix": func(suf, s string) bool { return strings.HasSuffix(s, suf) },
+	"split":     func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":      templateJoin,
+	"truncate":  func(n int, s string) string { return shortString(s, n) },
+}
+
+// Capitalize the first letter of each word.
+func titleCase(s string) string {
+	var prev = ' '
+	return strings.Map(func(r rune) rune {
+		var first = !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && prev != '_' && prev != '\''
+		prev = r
+		if first {
+			return unicode.ToTitle(r)
+		}
+		return r
+	}, s)
+}
+
+// Join array values, of any type.
+func templateJoin(sep string, val interface{}) string {
+	switch v := val.(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case []interface{}:
+		return arrayJoin(v, sep)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(val)
+}
+
+// Get file data of a template, probing local media files.
+func getTemplateFile(pth string) templateFile {
+	var ans = templateFile{Path: pth}
+	if pth == "" {
+		return ans
+	}
+	ans.Name = filepath.Base(pth)
+	ans.Ext = filepath.Ext(pth)
+	ans.Base = strings.TrimSuffix(ans.Name, ans.Ext)
+	ans.Dir = filepath.Dir(pth)
+	if fi, err := os.Stat(pth); err == nil {
+		ans.Size = fi.Size()
+		ans.ModTime = fi.ModTime()
+	}
+	if info, err := uploader.Probe(pth); err == nil {
+		ans.MediaInfo = info
+	}
+	return ans
+}
+
+// Get environment variables, by name.
+func getTemplateEnv() map[string]string {
+	var ans = map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			ans[kv[:i]] = kv[i+1:]
+		}
+	}
+	return ans
+}
+
+// Get data of templates, for a video and its thumbnail.
+func getTemplateData(m *VideoMeta, video string, thumbnail string) *templateData {
+	return &templateData{
+		Meta:      m.JSON,
+		File:      getTemplateFile(video),
+		Thumbnail: getTemplateFile(thumbnail),
+		Env:       getTemplateEnv(),
+		Now:       time.Now(),
+	}
+}
+
+// Render a template, where "${a.b}" is short for {{meta "a.b"}}.
+func renderTemplate(txt string, d *templateData) (string, error) {
+	if !strings.Contains(txt, "{{") && !strings.Contains(txt, "${") {
+		return txt, nil
+	}
+	txt = reTemplate.ReplaceAllStringFunc(txt, func(m string) string {
+		return fmt.Sprintf("{{meta %q}}", m[2:len(m)-1])
+	})
+	var funcs = template.FuncMap{
+		"meta": func(pth string) string {
+			var val = mapGet(d.Meta, pth)
+			if arr, ok := val.([]interface{}); ok {
+				return arrayJoin(arr, ",")
+			}
+			if val == nil {
+				return ""
+			}
+			return fmt.Sprintf("%v", val)
+		},
+		"orEmpty": func(val interface{}) interface{} {
+			if val == nil {
+				return ""
+			}
+			return val
+		},
+	}
+	t, err := template.New("").Funcs(templateFuncs).Funcs(funcs).Parse(txt)
+	if err != nil {
+		return "", err
+	}
+	for _, x := range t.Templates() {
+		pipeActions(x.Tree.Root, "orEmpty")
+	}
+	var sb strings.Builder
+	if err = t.Execute(&sb, d); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Pipe values printed by a template into a function, so a missing META
+// field prints as "" instead of "<no value>".
+func pipeActions(n parse.Node, fn string) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			pipeActions(c, fn)
+		}
+	case *parse.ActionNode:
+		// variable declarations print nothing
+		if len(n.Pipe.Decl) == 0 {
+			var cmd = &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{parse.NewIdentifier(fn).SetPos(n.Pos)}}
+			n.Pipe.Cmds = append(n.Pipe.Cmds, cmd)
+		}
+	case *parse.IfNode:
+		pipeActions(n.List, fn)
+		pipeActions(n.ElseList, fn)
+	case *parse.RangeNode:
+		pipeActions(n.List, fn)
+		pipeActions(n.ElseList, fn)
+	case *parse.WithNode:
+		pipeActions(n.List, fn)
+		pipeActions(n.ElseList, fn)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	var d = &templateData{
+		Meta: map[string]interface{}{
+			"title": "Trip",
+			"tags":  []interface{}{"travel", "paris"},
+			"show":  map[string]interface{}{"name": "Walks", "season": 2.0},
+			"note":  "<no value>",
+		},
+		File: templateFile{Name: "ep01.mp4", Base: "ep01", MediaInfo: uploader.MediaInfo{Duration: 95 * time.Second}},
+		Env:  map[string]string{"USER": "me"},
+	}
+	var tests = []struct {
+		txt  string
+		want string
+	}{
+		{"plain ${text", "plain ${text"},
+		{"${title} - ${show.name} S${show.season}", "Trip - Walks S2"},
+		{"${tags}", "travel,paris"},
+		{"${missing}${show.missing}", ""},
+		{"{{.Meta.title}} [{{.Meta.missing}}] [{{.Meta.show.missing}}]", "Trip [] []"},
+		{"{{.Meta.note}}", "<no value>"},
+		{"{{range .Meta.tags}}#{{.}} {{end}}{{range .Meta.missing}}x{{end}}", "#travel #paris "},
+		{"{{if .Meta.guest}}With {{.Meta.guest}}{{else}}Solo{{.Meta.guest}}{{end}}", "Solo"},
+		{"{{$g := .Meta.guest}}[{{$g}}]", "[]"},
+		{"{{default \"travel\" .Meta.place}} {{default \"x\" .Meta.title}}", "travel Trip"},
+		{"{{join \",\" .Meta.tags}}|{{join \",\" .Meta.missing}}", "travel,paris|"},
+		{"{{.File.Base | title}} ({{clock .File.Duration}}) by {{.Env.USER}}{{.Env.NONE}}", "Ep01 (1:35) by me"},
+		{"{{date \"Jan 2006\" \"2020-01-02\"}}", "Jan 2020"},
+		{"{{truncate 4 .Meta.title}}{{upper \"!\"}}", "Trip!"},
+	}
+	for _, tt := range tests {
+		if got, err := renderTemplate(tt.txt, d); err != nil || got != tt.want {
+			t.Errorf("renderTemplate(%q) = %q, %v, want %q", tt.txt, got, err, tt.want)
+		}
+	}
+	for _, txt := range []string{"{{.Meta.title", "{{nofunc .Meta}}"} {
+		if _, err := renderTemplate(txt, d); err == nil {
+			t.Errorf("renderTemplate(%q) should fail", txt)
+		}
+	}
+	// no META file
+	if got, err := renderTemplate("[{{.Meta.title}}${title}]", &templateData{}); err != nil || got != "[]" {
+		t.Errorf("renderTemplate without meta = %q, %v, want []", got, err)
+	}
+}
+
+func TestTitleCase(t *testing.T) {
+	var tests = []struct {
+		txt  string
+		want string
+	}{
+		{"my trip to paris", "My Trip To Paris"},
+		{"ep01_final-cut", "Ep01_final-Cut"},
+		{"don't stop", "Don't Stop"},
+		{"2nd day (été)", "2nd Day (Été)"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := titleCase(tt.txt); got != tt.want {
+			t.Errorf("titleCase(%q) = %q, want %q", tt.txt, got, tt.want)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //
@@ -12,6 +13,21 @@ import (
 //
 type chanChan chan chan struct{}
 
+// videoJob is a video to upload (or update), with its related files.
+type videoJob struct {
+	Id        string
+	Video     string
+	Thumbnail string
+	Captions  []CaptionMeta
+	Meta      VideoMeta
+	Result    jobResult
+	// share of upload rate, relative to other uploads (1)
+	Priority int
+	// video uploaded before (by content hash, or before switching
+	// credential), only its metadata is updated
+	Existing bool
+}
+
 //
 // Global variables
 //
@@ -21,6 +37,12 @@ var defCategoryID = 22
 var defPrivacyStatus = "public"
 var defLicense = ""
 
+// Video file extensions supported by YouTube.
+var videoExts = map[string]bool{
+	".mp4": true, ".m4v": true, ".mov": true, ".mkv": true, ".webm": true, ".avi": true,
+	".wmv": true, ".flv": true, ".mpg": true, ".mpeg": true, ".3gp": true, ".ts": true,
+}
+
 // Regexps
 var reOpen = regexp.MustCompile("(?i)open|free|public|common|creative")
 
@@ -96,6 +118,14 @@ func parseFloat(txt string, def float64) float64 {
 	return ans
 }
 
+func parseDuration(txt string, def time.Duration) time.Duration {
+	ans, err := time.ParseDuration(txt)
+	if err != nil {
+		return def
+	}
+	return ans
+}
+
 func parseString(txt string, def string) string {
 	if txt != "" {
 		return txt
@@ -116,6 +146,16 @@ func parseCategory(txt string) int {
 	return defCategoryID
 }
 
+// Check if a category id is known.
+func isCategoryID(id int) bool {
+	for _, v := range categoryName {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
 func parsePrivacyStatus(txt string) string {
 	if txt == "" || reOpen.FindString(txt) == "" {
 		return defPrivacyStatus
@@ -146,6 +186,16 @@ func stringsIncludes(arr []string, val string) bool {
 	return false
 }
 
+// Check if a list has a string, ignoring case.
+func stringsIncludesFold(arr []string, txt string) bool {
+	for _, v := range arr {
+		if strings.EqualFold(v, txt) {
+			return true
+		}
+	}
+	return false
+}
+
 func arrayJoin(arr []interface{}, sep string) string {
 	var sb strings.Builder
 	for _, val := range arr {
@@ -167,13 +217,3 @@ func mapGet(obj map[string]interface{}, pth string) interface{} {
 	}
 	return nil
 }
-
-func mapString(txt string, obj map[string]interface{}) string {
-	return reTemplate.ReplaceAllStringFunc(txt, func(m string) string {
-		var val = mapGet(obj, m[2:len(m)-1])
-		if arr, ok := val.([]interface{}); ok {
-			return arrayJoin(arr, ",")
-		}
-		return fmt.Sprintf("%v", val)
-	})
-}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Video part of each META field, which can be updated.
+var updateFieldParts = map[string]string{
+	"title":               "snippet",
+	"description":         "snippet",
+	"tags":                "snippet",
+	"categoryId":          "snippet",
+	"language":            "snippet",
+	"audioLanguage":       "snippet",
+	"privacyStatus":       "status",
+	"embeddable":          "status",
+	"license":             "status",
+	"publicStatsViewable": "status",
+	"publishAt":           "status",
+	"recordingDate":       "recordingDetails",
+	"location":            "recordingDetails",
+	"locationDescription": "recordingDetails",
+	"localizations":       "localizations",
+}
+
+// Parts fetched to update a video.
+var updateParts = []string{"snippet", "status", "recordingDetails", "localizations"}
+
+// Read-only fields, ignored so listed or exported videos can be updated.
+var readOnlyFields = map[string]bool{"id": true, "url": true, "publishedAt": true, "thumbnails": true}
+
+// Check that META fields of a row can be updated.
+func checkPatchFields(m *VideoMeta) error {
+	for k := range m.JSON {
+		if _, ok := updateFieldParts[k]; !ok && !readOnlyFields[k] {
+			return fmt.Errorf("Field '%s' can't be updated", k)
+		}
+	}
+	return nil
+}
+
+// Get parts having changed fields.
+func changedParts(changes []fieldChange) []string {
+	var ans []string
+	for _, c := range changes {
+		var p = strings.SplitN(c.Field, ".", 2)[0]
+		if !stringsIncludes(ans, p) {
+			ans = append(ans, p)
+		}
+	}
+	return ans
+}
+
+// Set video fields present in META, leaving others as is.
+func patchVideo(y *youtube.Video, m *VideoMeta) {
+	var has = func(k string) bool {
+		_, ok := m.JSON[k]
+		return ok
+	}
+	if y.Snippet == nil {
+		y.Snippet = &youtube.VideoSnippet{}
+	}
+	if y.Status == nil {
+		y.Status = &youtube.VideoStatus{}
+	}
+	if y.RecordingDetails == nil {
+		y.RecordingDetails = &youtube.VideoRecordingDetails{}
+	}
+	var s, st, rd = y.Snippet, y.Status, y.RecordingDetails
+	if has("title") {
+		s.Title = m.Title
+	}
+	if has("description") {
+		s.Description = m.Description
+	}
+	if has("tags") {
+		s.Tags = m.Tags
+	}
+	if has("categoryId") {
+		s.CategoryId = m.CategoryId
+	}
+	if has("language") {
+		s.DefaultLanguage = m.Language
+		s.DefaultAudioLanguage = m.Language
+	}
+	if has("audioLanguage") {
+		s.DefaultAudioLanguage = m.AudioLanguage
+	}
+	if has("privacyStatus") {
+		st.PrivacyStatus = m.PrivacyStatus
+	}
+	if has("embeddable") && st.Embeddable != m.Embeddable {
+		st.Embeddable = m.Embeddable
+		st.ForceSendFields = append(st.ForceSendFields, "Embeddable")
+	}
+	if has("license") {
+		st.License = m.License
+	}
+	if has("publicStatsViewable") && st.PublicStatsViewable != m.PublicStatsViewable {
+		st.PublicStatsViewable = m.PublicStatsViewable
+		st.ForceSendFields = append(st.ForceSendFields, "PublicStatsViewable")
+	}
+	if has("publishAt") {
+		st.PublishAt = m.PublishAt
+		applyPublishAt(st, time.Now())
+	}
+	if has("recordingDate") {
+		rd.RecordingDate = ""
+		if m.RecordingDate != nil {
+			rd.RecordingDate = m.RecordingDate.UTC().Format(ytDateLayout)
+		}
+	}
+	if has("location") {
+		rd.Location = m.Location
+	}
+	if has("locationDescription") {
+		rd.LocationDescription = m.LocationDescription
+	}
+	if has("localizations") {
+		if y.Localizations == nil {
+			y.Localizations = map[string]youtube.VideoLocalization{}
+		}
+		for k, l := range m.Localizations {
+			l.Title = parseString(l.Title, parseString(y.Localizations[k].Title, s.Title))
+			y.Localizations[k] = l
+		}
+	}
+}
+
+// Flatten a JSON value into field paths, with leaf values as JSON.
+func flattenJSON(pre string, val interface{}, out map[string]string) {
+	if obj, ok := val.(map[string]interface{}); ok {
+		for k, v := range obj {
+			flattenJSON(pre+"."+k, v, out)
+		}
+		return
+	}
+	dat, _ := json.Marshal(val)
+	out[pre] = string(dat)
+}
+
+// Get fields of the given parts of a video, by path ex- "snippet.title".
+func videoFields(y *youtube.Video, parts []string) map[string]string {
+	var ans = map[string]string{}
+	var obj map[string]interface{}
+	dat, _ := json.Marshal(y)
+	json.Unmarshal(dat, &obj)
+	for _, p := range parts {
+		if v, ok := obj[p]; ok {
+			flattenJSON(p, v, ans)
+		}
+	}
+	return ans
+}
+
+// Check if two JSON values are the same time, in different layouts.
+func sameTime(a string, b string) bool {
+	var ta, tb time.Time
+	return json.Unmarshal([]byte(a), &ta) == nil && json.Unmarshal([]byte(b), &tb) == nil && ta.Equal(tb)
+}
+
+// Get changed fields, sorted by path. Times are compared as such.
+func diffFields(old map[string]string, now map[string]string) []fieldChange {
+	var ans []fieldChange
+	for k, v := range now {
+		if old[k] != v && !sameTime(old[k], v) {
+			ans = append(ans, fieldChange{k, old[k], v})
+		}
+	}
+	for k, v := range old {
+		if _, ok := now[k]; !ok {
+			ans = append(ans, fieldChange{k, v, ""})
+		}
+	}
+	sort.Slice(ans, func(i, j int) bool { return ans[i].Field < ans[j].Field })
+	return ans
+}
+
+// Print changed fields, as "field: old -> new".
+func printChanges(id string, changes []fieldChange) {
+	if len(changes) == 0 {
+		printf("%s: no changes\n", id)
+		return
+	}
+	printf("%s:\n", id)
+	for _, c := range changes {
+		printf("  %s: %s -> %s\n", c.Field, parseString(c.Old, "(none)"), parseString(c.New, "(none)"))
+	}
+}
+
+// Update fields of a video given in a row, showing the changes.
+func updateRow(api *apiClient, dat []byte, res *jobResult) (string, error) {
+	var r batchRow
+	if err := json.Unmarshal(dat, &r); err != nil {
+		return "", err
+	}
+	m, err := ParseVideoMeta(dat)
+	if err != nil {
+		return "", err
+	}
+	if r.Id == "" {
+		return "", fmt.Errorf("Row has no id")
+	}
+	if err = checkPatchFields(&m); err != nil {
+		return r.Id, err
+	}
+	y, err := uploader.GetVideo(api.service, r.Id, updateParts)
+	if err != nil {
+		return r.Id, err
+	}
+	var old = videoFields(y, updateParts)
+	patchVideo(y, &m)
+	problems, err := validateVideo(y, templateFile{}, nil, old)
+	if err != nil {
+		return r.Id, err
+	}
+	for _, p := range problems {
+		printf("Warning: %s (fixed)\n", p)
+		res.Warnings = append(res.Warnings, p.String())
+	}
+	res.Changes = diffFields(old, videoFields(y, updateParts))
+	res.Metadata = y
+	printChanges(r.Id, res.Changes)
+	if len(res.Changes) == 0 {
+		res.Action = "unchanged"
+		return r.Id, nil
+	}
+	var parts = changedParts(res.Changes)
+	if f.DryRun {
+		printDryRun([]*dryRequest{newDryRequest("PUT", "videos", parts, y)})
+		return r.Id, nil
+	}
+	var h = &historyRecord{Op: historyUpdate, Started: time.Now(), Id: r.Id}
+	_, err = uploader.UpdateVideoIfUnchanged(api.service, y, updateParts, parts)
+	addHistory(h, err)
+	if err != nil {
+		return r.Id, err
+	}
+	res.Action = "updated"
+	return r.Id, nil
+}
+
+// Update existing videos from a manifest keyed by id, continuing past
+// failed rows. Returns exit code of the first failed row, if any.
+func runUpdate(api *apiClient, nam string) int {
+	rows, err := readBatchManifest(nam)
+	if err != nil {
+		printf("Error reading manifest '%s': %v\n", nam, err)
+		return exitError
+	}
+	var ans []batchResult
+	var out = []*jobResult{}
+	var code = exitOK
+	for i, dat := range rows {
+		var r = batchResult{Row: i + 1}
+		var res = &jobResult{Event: "result", Row: i + 1, Started: time.Now()}
+		r.Id, err = updateRow(api, dat, res)
+		res.finish(r.Id, err)
+		if err != nil {
+			printf("[%d/%d] %v\n", i+1, len(rows), err)
+			r.Err = err
+			if code == exitOK {
+				code = exitCode(err)
+			}
+		}
+		ans = append(ans, r)
+		out = append(out, res)
+		if f.Output == outputJSONL && !f.DryRun {
+			writeOutput(res)
+		}
+	}
+	if f.Output == outputJSON && !f.DryRun {
+		writeOutput(out)
+	} else if !outputIsJSON() {
+		printBatchResults(ans)
+	}
+	return code
+}
+
+// Update existing videos, as "update [options] <manifest>".
+func onUpdate(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	getFlags()
+	uploader.Logf = logf
+	var nam = parseString(flag.Arg(0), f.Batch)
+	if nam == "" {
+		printf("No manifest to update from!\n")
+		os.Exit(exitError)
+	}
+	os.Exit(runUpdate(getAPIClient(), nam))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestDiffFields(t *testing.T) {
+	var old = map[string]string{
+		"snippet.title":    `"a"`,
+		"snippet.tags":     `["x"]`,
+		"status.publishAt": `"2030-01-01T00:00:00Z"`,
+	}
+	var now = map[string]string{
+		"snippet.title":    `"b"`,
+		"status.publishAt": `"2030-01-01T01:00:00.000+01:00"`,
+	}
+	var got = diffFields(old, now)
+	if len(got) != 2 || got[0] != (fieldChange{"snippet.tags", `["x"]`, ""}) || got[1] != (fieldChange{"snippet.title", `"a"`, `"b"`}) {
+		t.Errorf("diffFields = %v, want tags and title only", got)
+	}
+	now["status.publishAt"] = `"2030-01-01T00:00:01Z"`
+	if got = diffFields(old, now); len(got) != 3 {
+		t.Errorf("diffFields = %v, want publishAt changed", got)
+	}
+}
+
+func TestUpdateRow(t *testing.T) {
+	_, api, dir := newTestAPI(t)
+	f.Title = "old"
+	id, err := api.runJob(&videoJob{Video: writeTestFile(t, dir, "ep06.mp4", []byte("video"))})
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	// category missing from table, publish time in YouTube layout
+	var publishAt = time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	var y = &youtube.Video{Id: id, Snippet: &youtube.VideoSnippet{Title: "old", CategoryId: "34"}, Status: &youtube.VideoStatus{PrivacyStatus: "private", PublishAt: publishAt.Format(time.RFC3339)}}
+	if _, err = api.service.Videos.Update([]string{"snippet", "status"}, y).Do(); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		row    string
+		action string
+		fields int
+	}{
+		{`{"id": "%s", "title": "new"}`, "updated", 1},
+		{`{"id": "%s", "publishAt": "` + publishAt.In(time.FixedZone("", 3600)).Format(time.RFC3339) + `"}`, "unchanged", 0},
+		{`{"id": "%s", "categoryId": "34", "title": "new"}`, "unchanged", 0},
+		{`{"id": "%s", "categoryId": "10"}`, "updated", 1},
+	}
+	for _, tt := range tests {
+		var row = fmt.Sprintf(tt.row, id)
+		var res = &jobResult{}
+		if _, err = updateRow(api, []byte(row), res); err != nil {
+			t.Errorf("updateRow(%s): %v", row, err)
+			continue
+		}
+		if res.Action != tt.action || len(res.Changes) != tt.fields {
+			t.Errorf("updateRow(%s) = %s %v, want %s %d fields", row, res.Action, res.Changes, tt.action, tt.fields)
+		}
+	}
+	if _, err = updateRow(api, []byte(fmt.Sprintf(`{"id": "%s", "categoryId": "99"}`, id)), &jobResult{}); err == nil {
+		t.Errorf("updateRow should refuse category 99")
+	}
+}
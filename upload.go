@@ -1,14 +1,13 @@
 package main
 
 import (
-	"io"
-	"log"
-	"os"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
-	"google.golang.org/api/googleapi"
 	"google.golang.org/api/youtube/v3"
 )
 
@@ -16,70 +15,102 @@ import (
 // Global variables
 //
 var reTemplate = regexp.MustCompile("\\$\\{.*?\\}")
-var retries = 8
+var reInvalidChars = regexp.MustCompile("<|>")
 
 //
 // Functions
 //
+// Remove characters not allowed in titles, descriptions and tags.
+func removeInvalid(txt string) string {
+	return reInvalidChars.ReplaceAllString(txt, "")
+}
+
 // Limit title to 100 characters.
 func limitTitle(txt string) string {
-	var re = regexp.MustCompile("<|>")
-	txt = re.ReplaceAllString(txt, "")
-	if len(txt) <= 100 {
+	txt = removeInvalid(txt)
+	if utf8.RuneCountInString(txt) <= maxTitleChars {
 		return txt
 	}
-	return txt[0:96] + " ..."
+	return string([]rune(txt)[0:maxTitleChars-4]) + " ..."
 }
 
-// Limit description to 5000 characters.
+// Limit description to 5000 bytes, without cutting a character.
 func limitDescription(txt string) string {
-	var re = regexp.MustCompile("<|>")
-	txt = re.ReplaceAllString(txt, "")
-	if len(txt) <= 5000 {
+	txt = removeInvalid(txt)
+	if len(txt) <= maxDescriptionBytes {
 		return txt
 	}
-	return txt[0:4996] + " ..."
+	var n = maxDescriptionBytes - 4
+	for n > 0 && !utf8.RuneStart(txt[n]) {
+		n--
+	}
+	return txt[0:n] + " ..."
 }
 
-// Limit tags to 30, 450 characters.
+// Limit tags to 500 characters, dropping duplicates (ignoring case).
 func limitTags(tags []string) []string {
-	var l = 0
-	var z []string
+	var z = []string{}
+	var seen = map[string]bool{}
 	for _, tag := range tags {
-		var t = strings.ToLower(tag)
-		if len(t) > 30 || stringsIncludes(z, t) {
+		var t = strings.TrimSpace(removeInvalid(tag))
+		if t == "" || seen[strings.ToLower(t)] {
 			continue
 		}
-		if l+len(t)+2 > 450 {
+		if tagsLength(append(z, t)) > maxTagsChars {
 			break
 		}
+		seen[strings.ToLower(t)] = true
 		z = append(z, t)
-		l += len(t) + 2
 	}
 	return z
 }
 
-func getUploadFlagsDefault(y *youtube.Video) {
-	y.Snippet.Title = parseString(y.Snippet.Title, f.Video)
-	y.Snippet.Description = parseString(y.Snippet.Description, f.Video)
+// Limit localized titles and descriptions, dropping invalid languages.
+func limitLocalizations(y *youtube.Video) {
+	for k, l := range y.Localizations {
+		if !reLanguage.MatchString(k) {
+			delete(y.Localizations, k)
+			continue
+		}
+		l.Title = limitTitle(l.Title)
+		l.Description = limitDescription(l.Description)
+		y.Localizations[k] = l
+	}
+}
+
+func getUploadFlagsDefault(y *youtube.Video, nam string) {
+	y.Snippet.Title = parseString(y.Snippet.Title, nam)
+	y.Snippet.Description = parseString(y.Snippet.Description, nam)
 	y.Snippet.DefaultLanguage = parseString(y.Snippet.DefaultLanguage, "en")
 	y.Snippet.DefaultAudioLanguage = parseString(y.Snippet.DefaultAudioLanguage, "en")
 	y.Snippet.CategoryId = parseString(y.Snippet.CategoryId, "22")
 	y.Status.PrivacyStatus = parseString(y.Status.PrivacyStatus, "private")
 }
 
-func getUploadFlagsDynamic(y *youtube.Video, m *VideoMeta) {
+func getUploadFlagsDynamic(y *youtube.Video, d *templateData) error {
 	if y.Snippet.Tags == nil {
 		y.Snippet.Tags = []string{}
 	}
 	if f.Title != "" {
-		y.Snippet.Title = mapString(f.Title, m.JSON)
+		txt, err := renderTemplate(f.Title, d)
+		if err != nil {
+			return fmt.Errorf("Error in title template: %v", err)
+		}
+		y.Snippet.Title = txt
 	}
 	if f.Description != "" {
-		y.Snippet.Description = mapString(f.Description, m.JSON)
+		txt, err := renderTemplate(f.Description, d)
+		if err != nil {
+			return fmt.Errorf("Error in description template: %v", err)
+		}
+		y.Snippet.Description = txt
 	}
 	if f.Tags != "" {
-		y.Snippet.Tags = strings.Split(mapString(f.Tags, m.JSON), ",")
+		txt, err := renderTemplate(f.Tags, d)
+		if err != nil {
+			return fmt.Errorf("Error in tags template: %v", err)
+		}
+		y.Snippet.Tags = strings.Split(txt, ",")
 	}
 	if f.Category != "" {
 		y.Snippet.CategoryId = strconv.Itoa(parseCategory(f.Category))
@@ -101,143 +132,35 @@ func getUploadFlagsDynamic(y *youtube.Video, m *VideoMeta) {
 	y.Status.PublishAt = parseString(f.PublishAt, y.Status.PublishAt)
 	y.RecordingDetails.RecordingDate = parseString(f.RecordingDate, y.RecordingDetails.RecordingDate)
 	y.RecordingDetails.LocationDescription = parseString(f.LocationDescription, y.RecordingDetails.LocationDescription)
+	return nil
 }
 
-func getUploadFlags(y *youtube.Video, m *VideoMeta) {
-	getUploadFlagsDynamic(y, m)
-	getUploadFlagsDefault(y)
-	y.Snippet.Title = limitTitle(y.Snippet.Title)
-	y.Snippet.Description = limitDescription(y.Snippet.Description)
-	y.Snippet.Tags = limitTags(y.Snippet.Tags)
-}
-
-func searchVideoTitle(srv *youtube.Service, txt string) []string {
-	res, err := srv.Search.List([]string{"snippet"}).Type("video").MaxResults(50).Q(txt).Do()
-	if err != nil {
-		if res != nil {
-			log.Fatalf("Error searching video title  '%v': %v, %v", txt, err, res.HTTPStatusCode)
-		} else {
-			log.Fatalf("Error searching video title '%v': %v", txt, err)
-		}
+func getUploadFlags(y *youtube.Video, d *templateData, nam string) error {
+	if err := getUploadFlagsDynamic(y, d); err != nil {
+		return err
 	}
-	var ans = []string{}
-	var re = regexp.MustCompile("\\W")
-	var ta = strings.ToLower(re.ReplaceAllString(txt, ""))
-	for _, item := range res.Items {
-		var tb = strings.ToLower(re.ReplaceAllString(item.Snippet.Title, ""))
-		if ta == tb {
-			ans = append(ans, item.Id.VideoId)
-		}
+	getUploadFlagsDefault(y, nam)
+	applyPublishAt(y.Status, time.Now())
+	for k, l := range y.Localizations {
+		l.Title = parseString(l.Title, y.Snippet.Title)
+		y.Localizations[k] = l
 	}
-	return ans
+	return nil
 }
 
-func updateVideo(srv *youtube.Service, id string, obj *youtube.Video) {
-	obj.Id = id
-	res, err := srv.Videos.Update([]string{"snippet", "status", "recordingDetails"}, obj).Do()
-	if err != nil {
-		if res != nil {
-			log.Fatalf("Error updating video: %v, %v", err, res.HTTPStatusCode)
-		} else {
-			log.Fatalf("Error updating video: %v", err)
-		}
-	}
-}
-
-func uploadVideo(srv *youtube.Service, fil io.ReadCloser, obj *youtube.Video, cnk int, cquit chanChan) *youtube.Video {
-	opt := googleapi.ChunkSize(cnk)
-	req := srv.Videos.Insert([]string{"snippet", "status", "recordingDetails"}, obj)
-	res, err := req.Media(fil, opt).Do()
-	if cquit != nil {
-		quit := make(chan struct{})
-		cquit <- quit
-		<-quit
-	}
-	if err != nil {
-		if res != nil {
-			log.Fatalf("Error making YouTube API call: %v, %v", err, res.HTTPStatusCode)
-		} else {
-			log.Fatalf("Error making YouTube API call: %v", err)
-		}
+// Merge upload flags into a fetched video, keeping fields not given.
+func mergeUploadFlags(y *youtube.Video, d *templateData) error {
+	if err := getUploadFlagsDynamic(y, d); err != nil {
+		return err
 	}
-	return res
+	applyPublishAt(y.Status, time.Now())
+	return nil
 }
 
-func uploadThumbnail(srv *youtube.Service, id string, fil io.ReadCloser) {
-	res, err := srv.Thumbnails.Set(id).Media(fil).Do()
-	if err != nil {
-		if res != nil {
-			log.Fatalf("Error uploading thumbnail: %v, %v", err, res.HTTPStatusCode)
-		} else {
-			log.Fatalf("Error uploading thumbnail: %v", err)
-		}
-	}
-}
-
-func uploadCaption(srv *youtube.Service, id string, lng string, fil io.ReadCloser) {
-	var err error
-	var res *youtube.Caption
-	c := &youtube.Caption{
-		Snippet: &youtube.CaptionSnippet{},
-	}
-	c.Snippet.VideoId = id
-	c.Snippet.Language = lng
-	c.Snippet.Name = lng
-	for i := 0; i < retries; i++ {
-		req := srv.Captions.Insert([]string{"snippet"}, c).Sync(true)
-		res, err = req.Media(fil).Do()
-		if err == nil {
-			break
-		} else if res == nil {
-			log.Printf("Error uploading caption: %v", err)
-		} else {
-			log.Printf("Error uploading caption: %v, %v", err, res.HTTPStatusCode)
-		}
-	}
-	if err != nil {
-		os.Exit(1)
-	}
-}
-
-func addToPlaylistID(srv *youtube.Service, pid string, sta string, id string) {
-	p := Playlistx{}
-	p.PrivacyStatus = sta
-	// PlaylistID is deprecated in favour of PlaylistIDs
-	p.Id = pid
-	err := p.AddVideoToPlaylist(srv, id)
-	if err != nil {
-		log.Fatalf("Error adding video to playlist: %s", err)
-	}
-}
-
-func addToPlaylistIDs(srv *youtube.Service, pids []string, sta string, id string) {
-	p := Playlistx{}
-	p.PrivacyStatus = sta
-	if len(pids) > 0 {
-		p.Title = ""
-		for _, pid := range pids {
-			p.Id = pid
-			err := p.AddVideoToPlaylist(srv, id)
-			if err != nil {
-				log.Fatalf("Error adding video to playlist: %s", err)
-			}
-		}
-	}
-}
-
-func addToPlaylistTitles(srv *youtube.Service, pnams []string, sta string, id string) {
-	p := Playlistx{}
-	if sta != "" {
-		p.PrivacyStatus = sta
-	}
-	if len(pnams) > 0 {
-		p.Id = ""
-		for _, nam := range pnams {
-			p.Title = nam
-			err := p.AddVideoToPlaylist(srv, id)
-			if err != nil {
-				log.Fatalf("Error adding video to playlist: %s", err)
-			}
-		}
-	}
+// Limit title, description, tags and localizations of a video.
+func limitUploadFlags(y *youtube.Video) {
+	y.Snippet.Title = limitTitle(y.Snippet.Title)
+	y.Snippet.Description = limitDescription(y.Snippet.Description)
+	y.Snippet.Tags = limitTags(y.Snippet.Tags)
+	limitLocalizations(y)
 }
@@ -0,0 +1,101 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Kinds of error returned by API calls, test with errors.Is.
+var (
+	ErrQuotaExceeded    = errors.New("quota exceeded")
+	ErrAuthExpired      = errors.New("authorization expired")
+	ErrVideoNotFound    = errors.New("video not found")
+	ErrPlaylistNotFound = errors.New("playlist not found")
+	ErrSessionExpired   = errors.New("upload session expired")
+	ErrVideoChanged     = errors.New("video changed")
+	ErrFile             = errors.New("file error")
+	ErrCanceled         = errors.New("canceled")
+)
+
+// Error is returned by API calls. It wraps the underlying error, which
+// can be a *googleapi.Error (test with errors.As).
+type Error struct {
+	Op   string
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("Error %s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the given kind.
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// Get reasons of an API error.
+func apiReasons(err *googleapi.Error) []string {
+	var ans []string
+	for _, itm := range err.Errors {
+		ans = append(ans, itm.Reason)
+	}
+	return ans
+}
+
+// Get kind of an error, nil if unknown.
+func errorKind(err error) error {
+	var e *Error
+	var ae *googleapi.Error
+	var re *oauth2.RetrieveError
+	if errors.As(err, &e) && e.Kind != nil {
+		return e.Kind
+	}
+	if errors.As(err, &re) {
+		return ErrAuthExpired
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrCanceled
+	}
+	if errors.As(err, &ae) {
+		for _, r := range apiReasons(ae) {
+			switch r {
+			case "quotaExceeded", "dailyLimitExceeded", "uploadLimitExceeded":
+				return ErrQuotaExceeded
+			case "authError", "expired", "invalidCredentials":
+				return ErrAuthExpired
+			case "videoNotFound":
+				return ErrVideoNotFound
+			case "playlistNotFound":
+				return ErrPlaylistNotFound
+			}
+		}
+		if ae.Code == http.StatusUnauthorized {
+			return ErrAuthExpired
+		}
+	}
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		return ErrFile
+	}
+	return nil
+}
+
+// Wrap an error of an operation, keeping its kind.
+func wrapError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, Kind: errorKind(err), Err: err}
+}
@@ -0,0 +1,86 @@
+package uploader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func fileError(op string, filename string, err error) error {
+	return &Error{Op: op + " " + filename, Kind: ErrFile, Err: err}
+}
+
+// Open opens a file
+func Open(filename string) (io.ReadCloser, int64, error) {
+	var reader io.ReadCloser
+	var filesize int64
+	var err error
+	if strings.HasPrefix(filename, "http") {
+		resp, err := http.Head(filename)
+		if err != nil {
+			return reader, filesize, fileError("opening", filename, err)
+		}
+		lenStr := resp.Header.Get("content-length")
+		if lenStr != "" {
+			filesize, err = strconv.ParseInt(lenStr, 10, 64)
+			if err != nil {
+				return reader, filesize, fileError("opening", filename, err)
+			}
+		}
+
+		resp, err = http.Get(filename)
+		if err != nil {
+			return reader, filesize, fileError("opening", filename, err)
+		}
+		if resp.ContentLength != 0 {
+			filesize = resp.ContentLength
+		}
+		reader = resp.Body
+		return reader, filesize, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return reader, filesize, fileError("opening", filename, err)
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return reader, filesize, fileError("stat'ing", filename, err)
+	}
+
+	return file, fileInfo.Size(), nil
+}
+
+// OpenAt opens a file, starting at offset
+func OpenAt(filename string, offset int64) (io.ReadCloser, error) {
+	if strings.HasPrefix(filename, "http") {
+		req, err := http.NewRequest("GET", filename, nil)
+		if err != nil {
+			return nil, fileError("opening", filename, err)
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fileError("opening", filename, err)
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return nil, fileError("opening", filename, fmt.Errorf("range at %d not supported (%v)", offset, resp.StatusCode))
+		}
+		return resp.Body, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fileError("opening", filename, err)
+	}
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fileError("seeking", filename, err)
+	}
+	return file, nil
+}
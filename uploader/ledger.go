@@ -0,0 +1,113 @@
+package uploader
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// UploadEntry is a video uploaded from a file.
+type UploadEntry struct {
+	Id       string    `json:"id"`
+	File     string    `json:"file"`
+	Uploaded time.Time `json:"uploaded"`
+}
+
+// UploadLedger records ids of uploaded videos by content hash, in a file.
+type UploadLedger struct {
+	Videos map[string]UploadEntry `json:"videos"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// LoadUploadLedger loads a ledger file, which may not exist yet.
+func LoadUploadLedger(pth string) (*UploadLedger, error) {
+	var l = &UploadLedger{path: pth}
+	dat, err := ioutil.ReadFile(pth)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err = json.Unmarshal(dat, l); err != nil {
+			return nil, err
+		}
+	}
+	if l.Videos == nil {
+		l.Videos = map[string]UploadEntry{}
+	}
+	return l, nil
+}
+
+func (l *UploadLedger) save() error {
+	if l.path == "" {
+		return nil
+	}
+	dat, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.path, dat, 0600)
+}
+
+// Get gets id of the video uploaded with a content hash.
+func (l *UploadLedger) Get(hash string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.Videos[hash]
+	return e.Id, ok
+}
+
+// Add records a video uploaded with a content hash.
+func (l *UploadLedger) Add(hash string, id string, file string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Videos[hash] = UploadEntry{Id: id, File: file, Uploaded: time.Now().UTC()}
+	return l.save()
+}
+
+// Remove forgets a content hash, when its video no longer exists.
+func (l *UploadLedger) Remove(hash string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.Videos, hash)
+	return l.save()
+}
+
+// HashFile gets SHA-256 of a file, as "sha256:<hex>". With n > 0, only
+// its size and first and last n bytes are hashed, as "sha256-<n>:<hex>".
+func HashFile(nam string, n int64) (string, error) {
+	fil, err := os.Open(nam)
+	if err != nil {
+		return "", fileError("hashing", nam, err)
+	}
+	defer fil.Close()
+	fi, err := fil.Stat()
+	if err != nil {
+		return "", fileError("hashing", nam, err)
+	}
+	var h = sha256.New()
+	var pre = "sha256"
+	if n <= 0 || fi.Size() <= 2*n {
+		_, err = io.Copy(h, fil)
+	} else {
+		pre = fmt.Sprintf("sha256-%d", n)
+		binary.Write(h, binary.BigEndian, fi.Size())
+		if _, err = io.CopyN(h, fil, n); err == nil {
+			if _, err = fil.Seek(-n, io.SeekEnd); err == nil {
+				_, err = io.CopyN(h, fil, n)
+			}
+		}
+	}
+	if err != nil {
+		return "", fileError("hashing", nam, err)
+	}
+	return pre + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
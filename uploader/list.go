@@ -0,0 +1,99 @@
+package uploader
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// UploadsPlaylist returns id of the playlist having all uploads of the
+// channel, including private and unlisted videos.
+func UploadsPlaylist(srv *youtube.Service) (string, error) {
+	res, err := srv.Channels.List([]string{"contentDetails"}).Mine(true).Do()
+	if err != nil {
+		return "", wrapError("getting channel", err)
+	}
+	for _, c := range res.Items {
+		if c.ContentDetails != nil && c.ContentDetails.RelatedPlaylists != nil {
+			return c.ContentDetails.RelatedPlaylists.Uploads, nil
+		}
+	}
+	return "", &Error{Op: "getting channel", Err: fmt.Errorf("No channel for this account")}
+}
+
+// ListUploads returns all videos of the channel with the given parts,
+// newest first, reading the uploads playlist 50 videos at a time.
+func ListUploads(srv *youtube.Service, parts []string) ([]*youtube.Video, error) {
+	pid, err := UploadsPlaylist(srv)
+	if err != nil {
+		return nil, err
+	}
+	var ans []*youtube.Video
+	var token = ""
+	for {
+		res, err := srv.PlaylistItems.List([]string{"contentDetails"}).PlaylistId(pid).MaxResults(50).PageToken(token).Do()
+		if err != nil {
+			return nil, wrapError("listing uploads", err)
+		}
+		var ids []string
+		for _, itm := range res.Items {
+			ids = append(ids, itm.ContentDetails.VideoId)
+		}
+		if len(ids) > 0 {
+			vids, err := srv.Videos.List(parts).Id(ids...).Do()
+			if err != nil {
+				return nil, wrapError("listing videos", err)
+			}
+			ans = append(ans, vids.Items...)
+		}
+		Logf("Listed %d videos...\n", len(ans))
+		if token = res.NextPageToken; token == "" {
+			return ans, nil
+		}
+	}
+}
+
+// Non-word characters, ignored when matching titles.
+var reNonWord = regexp.MustCompile("\\W")
+
+// Get title without case and non-word characters, for matching.
+func normalizeTitle(txt string) string {
+	return strings.ToLower(reNonWord.ReplaceAllString(txt, ""))
+}
+
+// FindVideoTitle returns ids of videos of the channel matching a title,
+// including private and unlisted videos.
+func FindVideoTitle(srv *youtube.Service, txt string) ([]string, error) {
+	vids, err := ListUploads(srv, []string{"snippet"})
+	if err != nil {
+		return nil, err
+	}
+	var ans = []string{}
+	var ta = normalizeTitle(txt)
+	for _, v := range vids {
+		if normalizeTitle(v.Snippet.Title) == ta {
+			ans = append(ans, v.Id)
+		}
+	}
+	return ans, nil
+}
+
+// FindVideoTag returns ids of videos of the channel having a tag.
+func FindVideoTag(srv *youtube.Service, tag string) ([]string, error) {
+	vids, err := ListUploads(srv, []string{"snippet"})
+	if err != nil {
+		return nil, err
+	}
+	var ans = []string{}
+	for _, v := range vids {
+		for _, t := range v.Snippet.Tags {
+			if t == tag {
+				ans = append(ans, v.Id)
+				break
+			}
+		}
+	}
+	return ans, nil
+}
@@ -0,0 +1,120 @@
+package uploader
+
+import (
+	"fmt"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Playlist identifies a playlist by id or title.
+type Playlist struct {
+	Id            string
+	Title         string
+	PrivacyStatus string
+}
+
+// AddVideoToPlaylist adds a video to the playlist, by id or title.
+// A playlist with title is created if it doesn't exist.
+func (plx *Playlist) AddVideoToPlaylist(service *youtube.Service, videoID string) (err error) {
+	listCall := service.Playlists.List([]string{"snippet", "contentDetails"})
+	listCall = listCall.Mine(true)
+	response, err := listCall.Do()
+	if err != nil {
+		return wrapError("retrieving playlists", err)
+	}
+
+	var playlist *youtube.Playlist
+	for _, pl := range response.Items {
+		if pl.Id == plx.Id || pl.Snippet.Title == plx.Title {
+			playlist = pl
+			break
+		}
+	}
+
+	// create playlist if it doesn't exist
+	if playlist == nil {
+		if plx.Id != "" {
+			return &Error{Op: "finding playlist", Kind: ErrPlaylistNotFound, Err: fmt.Errorf("Playlist ID '%s' doesn't exist", plx.Id)}
+		}
+		playlist = &youtube.Playlist{}
+		playlist.Snippet = &youtube.PlaylistSnippet{Title: plx.Title}
+		playlist.Status = &youtube.PlaylistStatus{PrivacyStatus: plx.PrivacyStatus}
+		insertCall := service.Playlists.Insert([]string{"snippet", "status"}, playlist)
+		// API doesn't return playlist ID here!?
+		playlist, err = insertCall.Do()
+		if err != nil {
+			return wrapError(fmt.Sprintf("creating playlist with title '%s'", plx.Title), err)
+		}
+	}
+
+	// skip if already added, so meta can be applied again
+	found, err := playlistHasVideo(service, playlist.Id, videoID)
+	if err != nil {
+		return err
+	}
+	if found {
+		Logf("Video already in playlist '%s' (%s)\n", playlist.Snippet.Title, playlist.Id)
+		return nil
+	}
+
+	playlistItem := &youtube.PlaylistItem{}
+	playlistItem.Snippet = &youtube.PlaylistItemSnippet{PlaylistId: playlist.Id, Title: playlist.Snippet.Title}
+	playlistItem.Snippet.ResourceId = &youtube.ResourceId{
+		VideoId: videoID,
+		Kind:    "youtube#video",
+	}
+
+	insertCall := service.PlaylistItems.Insert([]string{"snippet"}, playlistItem)
+	_, err = insertCall.Do()
+	if err != nil {
+		return wrapError("inserting playlist item", err)
+	}
+
+	Logf("Video added to playlist '%s' (%s)\n", playlist.Snippet.Title, playlist.Id)
+
+	return nil
+}
+
+// Check if a playlist has a video.
+func playlistHasVideo(srv *youtube.Service, pid string, id string) (bool, error) {
+	res, err := srv.PlaylistItems.List([]string{"id"}).PlaylistId(pid).VideoId(id).Do()
+	if err != nil {
+		return false, wrapError("listing playlist items", err)
+	}
+	return len(res.Items) > 0, nil
+}
+
+// MyPlaylists returns all playlists of the channel.
+func MyPlaylists(srv *youtube.Service) ([]*youtube.Playlist, error) {
+	var ans []*youtube.Playlist
+	var token = ""
+	for {
+		res, err := srv.Playlists.List([]string{"snippet"}).Mine(true).MaxResults(50).PageToken(token).Do()
+		if err != nil {
+			return nil, wrapError("retrieving playlists", err)
+		}
+		ans = append(ans, res.Items...)
+		if token = res.NextPageToken; token == "" {
+			return ans, nil
+		}
+	}
+}
+
+// VideoPlaylists returns ids of the channel playlists having a video.
+func VideoPlaylists(srv *youtube.Service, id string) ([]string, error) {
+	pls, err := MyPlaylists(srv)
+	if err != nil {
+		return nil, err
+	}
+	var ans []string
+	for _, pl := range pls {
+		found, err := playlistHasVideo(srv, pl.Id, id)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			ans = append(ans, pl.Id)
+		}
+	}
+	return ans, nil
+}
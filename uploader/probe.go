@@ -0,0 +1,514 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// MediaInfo is information read from a local media file.
+type MediaInfo struct {
+	// Format is "mp4", "matroska", "webm", "jpeg", "png", "gif" or "bmp",
+	// empty if unknown.
+	Format     string
+	Duration   time.Duration
+	Created    time.Time
+	Width      int
+	Height     int
+	VideoCodec string
+	AudioCodec string
+	Location   *youtube.GeoPoint
+}
+
+// Epochs of MP4 and Matroska times.
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+var mkvEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Boxes which can start an MP4/MOV file.
+var mp4TopBoxes = map[string]bool{"ftyp": true, "moov": true, "mdat": true, "free": true, "wide": true, "skip": true}
+
+// Largest header box read in memory.
+const maxProbeBox = 64 << 20
+
+// Probe reads information of a local MP4/MOV or Matroska/WebM video, or
+// image (EXIF of JPEG). Unknown formats return empty information.
+func Probe(filename string) (MediaInfo, error) {
+	var ans MediaInfo
+	if strings.HasPrefix(filename, "http") {
+		return ans, nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return ans, fileError("probing", filename, err)
+	}
+	defer file.Close()
+	var head = make([]byte, 12)
+	if _, err = io.ReadFull(file, head); err != nil {
+		return ans, nil
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return ans, fileError("probing", filename, err)
+	}
+	switch {
+	case mp4TopBoxes[string(head[4:8])]:
+		ans.Format = "mp4"
+		err = probeMP4(file, &ans)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		ans.Format = "matroska"
+		err = probeMKV(file, &ans)
+	case head[0] == 0xFF && head[1] == 0xD8:
+		ans.Format = "jpeg"
+		err = probeJPEG(file, &ans)
+	case bytes.HasPrefix(head, []byte("\x89PNG")):
+		ans.Format = "png"
+	case bytes.HasPrefix(head, []byte("GIF8")):
+		ans.Format = "gif"
+	case bytes.HasPrefix(head, []byte("BM")):
+		ans.Format = "bmp"
+	}
+	if err != nil {
+		return ans, fileError("probing", filename, err)
+	}
+	return ans, nil
+}
+
+// Read MP4 box header, returns type and body size (-1 till end).
+func readMP4Box(r io.Reader) (string, int64, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return "", 0, err
+	}
+	var siz = int64(binary.BigEndian.Uint32(hdr[:4]))
+	var typ = string(hdr[4:])
+	switch siz {
+	case 0:
+		return typ, -1, nil
+	case 1:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return "", 0, err
+		}
+		return typ, int64(binary.BigEndian.Uint64(ext[:])) - 16, nil
+	}
+	return typ, siz - 8, nil
+}
+
+// Find moov box, skipping others, and parse it in memory.
+func probeMP4(r io.ReadSeeker, ans *MediaInfo) error {
+	for {
+		typ, siz, err := readMP4Box(r)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if typ == "moov" {
+			if siz < 0 || siz > maxProbeBox {
+				return fmt.Errorf("moov box too large")
+			}
+			var dat = make([]byte, siz)
+			if _, err = io.ReadFull(r, dat); err != nil {
+				return err
+			}
+			var p = mp4Probe{info: ans}
+			parseMP4Boxes(dat, p.box)
+			return nil
+		}
+		if siz < 0 {
+			return nil
+		}
+		if _, err = r.Seek(siz, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+}
+
+// Call fn with type and body of each box in dat.
+func parseMP4Boxes(dat []byte, fn func(typ string, body []byte)) {
+	for len(dat) >= 8 {
+		var siz = uint64(binary.BigEndian.Uint32(dat))
+		var hdr uint64 = 8
+		if siz == 1 && len(dat) >= 16 {
+			siz, hdr = binary.BigEndian.Uint64(dat[8:]), 16
+		} else if siz == 0 {
+			siz = uint64(len(dat))
+		}
+		if siz < hdr || siz > uint64(len(dat)) {
+			return
+		}
+		fn(string(dat[4:8]), dat[hdr:siz])
+		dat = dat[siz:]
+	}
+}
+
+// mp4Probe walks the moov box, one track at a time.
+type mp4Probe struct {
+	info    *MediaInfo
+	handler string
+	codec   string
+	width   int
+	height  int
+}
+
+func (p *mp4Probe) box(typ string, body []byte) {
+	switch typ {
+	case "moov", "mdia", "minf", "stbl", "udta":
+		parseMP4Boxes(body, p.box)
+	case "trak":
+		p.handler, p.codec, p.width, p.height = "", "", 0, 0
+		parseMP4Boxes(body, p.box)
+		if p.handler == "vide" && p.info.VideoCodec == "" {
+			p.info.VideoCodec, p.info.Width, p.info.Height = p.codec, p.width, p.height
+		}
+		if p.handler == "soun" && p.info.AudioCodec == "" {
+			p.info.AudioCodec = p.codec
+		}
+	case "mvhd":
+		parseMvhd(body, p.info)
+	case "tkhd":
+		// width and height are 16.16 fixed point, at the end
+		if len(body) >= 84 {
+			p.width = int(binary.BigEndian.Uint32(body[len(body)-8:]) >> 16)
+			p.height = int(binary.BigEndian.Uint32(body[len(body)-4:]) >> 16)
+		}
+	case "hdlr":
+		if len(body) >= 12 {
+			p.handler = string(body[8:12])
+		}
+	case "stsd":
+		// first sample entry type is the codec
+		if len(body) >= 16 {
+			p.codec = strings.TrimSpace(string(body[12:16]))
+		}
+	case "\xa9xyz":
+		// ISO 6709 location, after size and language
+		if len(body) > 4 {
+			p.info.Location = parseISO6709(string(body[4:]))
+		}
+	}
+}
+
+// Parse movie header, version 0 or 1.
+func parseMvhd(dat []byte, ans *MediaInfo) {
+	var created, scale, dur uint64
+	if len(dat) >= 32 && dat[0] == 1 {
+		created = binary.BigEndian.Uint64(dat[4:12])
+		scale = uint64(binary.BigEndian.Uint32(dat[20:24]))
+		dur = binary.BigEndian.Uint64(dat[24:32])
+	} else if len(dat) >= 20 {
+		created = uint64(binary.BigEndian.Uint32(dat[4:8]))
+		scale = uint64(binary.BigEndian.Uint32(dat[12:16]))
+		dur = uint64(binary.BigEndian.Uint32(dat[16:20]))
+	}
+	if created > 0 {
+		ans.Created = mp4Epoch.Add(time.Duration(created) * time.Second)
+	}
+	if scale > 0 {
+		ans.Duration = time.Duration(float64(dur) / float64(scale) * float64(time.Second))
+	}
+}
+
+// Regexps
+var reISO6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?`)
+
+// Parse ISO 6709 location in decimal degrees, ex- "+48.8584+002.2945+035.000/".
+func parseISO6709(txt string) *youtube.GeoPoint {
+	var m = reISO6709.FindStringSubmatch(txt)
+	if m == nil {
+		return nil
+	}
+	var ans = &youtube.GeoPoint{}
+	ans.Latitude, _ = strconv.ParseFloat(m[1], 64)
+	ans.Longitude, _ = strconv.ParseFloat(m[2], 64)
+	if m[3] != "" {
+		ans.Altitude, _ = strconv.ParseFloat(m[3], 64)
+	}
+	return ans
+}
+
+// Matroska element ids
+const (
+	mkvEBML          = 0x1A45DFA3
+	mkvDocType       = 0x4282
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvDateUTC       = 0x4461
+	mkvTracks        = 0x1654AE6B
+	mkvTrackEntry    = 0xAE
+	mkvTrackType     = 0x83
+	mkvCodecID       = 0x86
+	mkvVideo         = 0xE0
+	mkvPixelWidth    = 0xB0
+	mkvPixelHeight   = 0xBA
+)
+
+// Matroska values
+const (
+	mkvUnknownSize  = -1
+	mkvTrackVideo   = 1
+	mkvTrackAudio   = 2
+	mkvDefaultScale = 1000000
+)
+
+// Read an EBML variable length integer, returns value and its length.
+// The length marker is kept for ids.
+func readVint(r io.Reader, id bool) (int64, int, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:1]); err != nil {
+		return 0, 0, err
+	}
+	var n = 1
+	for n <= 8 && b[0]&(0x80>>uint(n-1)) == 0 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, fmt.Errorf("invalid EBML integer")
+	}
+	if _, err := io.ReadFull(r, b[1:n]); err != nil {
+		return 0, 0, err
+	}
+	var val = int64(b[0])
+	if !id {
+		val &= int64(0xFF >> uint(n))
+	}
+	var ones = val == int64(0xFF>>uint(n))
+	for i := 1; i < n; i++ {
+		val = val<<8 | int64(b[i])
+		ones = ones && b[i] == 0xFF
+	}
+	if !id && ones {
+		return mkvUnknownSize, n, nil
+	}
+	return val, n, nil
+}
+
+// Read an EBML element header, returns id and body size.
+func readEBML(r io.Reader) (uint32, int64, error) {
+	id, _, err := readVint(r, true)
+	if err != nil {
+		return 0, 0, err
+	}
+	siz, _, err := readVint(r, false)
+	return uint32(id), siz, err
+}
+
+// Call fn with id and body of each element in dat.
+func parseEBML(dat []byte, fn func(id uint32, body []byte)) {
+	var r = bytes.NewReader(dat)
+	for r.Len() > 0 {
+		id, siz, err := readEBML(r)
+		if err != nil || siz < 0 || siz > int64(r.Len()) {
+			return
+		}
+		var body = make([]byte, siz)
+		r.Read(body)
+		fn(id, body)
+	}
+}
+
+func ebmlUint(dat []byte) uint64 {
+	var ans uint64
+	for _, b := range dat {
+		ans = ans<<8 | uint64(b)
+	}
+	return ans
+}
+
+func ebmlFloat(dat []byte) float64 {
+	switch len(dat) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(dat)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(dat))
+	}
+	return 0
+}
+
+// Read Matroska header, and info and tracks of first segment.
+func probeMKV(r io.ReadSeeker, ans *MediaInfo) error {
+	id, siz, err := readEBML(r)
+	if err != nil || id != mkvEBML || siz < 0 || siz > maxProbeBox {
+		return err
+	}
+	var dat = make([]byte, siz)
+	if _, err = io.ReadFull(r, dat); err != nil {
+		return err
+	}
+	parseEBML(dat, func(id uint32, body []byte) {
+		if id == mkvDocType && string(body) == "webm" {
+			ans.Format = "webm"
+		}
+	})
+	if id, _, err = readEBML(r); err != nil || id != mkvSegment {
+		return nil
+	}
+	var info, tracks bool
+	for !info || !tracks {
+		id, siz, err = readEBML(r)
+		if err != nil || siz < 0 {
+			return nil
+		}
+		if (id != mkvInfo && id != mkvTracks) || siz > maxProbeBox {
+			if _, err = r.Seek(siz, io.SeekCurrent); err != nil {
+				return err
+			}
+			continue
+		}
+		var dat = make([]byte, siz)
+		if _, err = io.ReadFull(r, dat); err != nil {
+			return err
+		}
+		if id == mkvInfo {
+			parseMKVInfo(dat, ans)
+			info = true
+		} else {
+			parseEBML(dat, func(id uint32, body []byte) {
+				if id == mkvTrackEntry {
+					parseMKVTrack(body, ans)
+				}
+			})
+			tracks = true
+		}
+	}
+	return nil
+}
+
+func parseMKVInfo(dat []byte, ans *MediaInfo) {
+	var scale uint64 = mkvDefaultScale
+	var dur float64
+	parseEBML(dat, func(id uint32, body []byte) {
+		switch id {
+		case mkvTimecodeScale:
+			scale = ebmlUint(body)
+		case mkvDuration:
+			dur = ebmlFloat(body)
+		case mkvDateUTC:
+			ans.Created = mkvEpoch.Add(time.Duration(int64(ebmlUint(body))))
+		}
+	})
+	ans.Duration = time.Duration(dur * float64(scale))
+}
+
+func parseMKVTrack(dat []byte, ans *MediaInfo) {
+	var typ uint64
+	var codec string
+	var width, height int
+	parseEBML(dat, func(id uint32, body []byte) {
+		switch id {
+		case mkvTrackType:
+			typ = ebmlUint(body)
+		case mkvCodecID:
+			codec = string(body)
+		case mkvVideo:
+			parseEBML(body, func(id uint32, body []byte) {
+				switch id {
+				case mkvPixelWidth:
+					width = int(ebmlUint(body))
+				case mkvPixelHeight:
+					height = int(ebmlUint(body))
+				}
+			})
+		}
+	})
+	if typ == mkvTrackVideo && ans.VideoCodec == "" {
+		ans.VideoCodec, ans.Width, ans.Height = codec, width, height
+	}
+	if typ == mkvTrackAudio && ans.AudioCodec == "" {
+		ans.AudioCodec = codec
+	}
+}
+
+// Find EXIF segment in JPEG, and read its original date.
+func probeJPEG(r io.Reader, ans *MediaInfo) error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:2]); err != nil {
+		return err
+	}
+	for {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil
+		}
+		var siz = int(binary.BigEndian.Uint16(hdr[2:])) - 2
+		// stop at start of scan, or invalid marker
+		if hdr[0] != 0xFF || hdr[1] == 0xDA || siz < 0 {
+			return nil
+		}
+		var dat = make([]byte, siz)
+		if _, err := io.ReadFull(r, dat); err != nil {
+			return nil
+		}
+		if hdr[1] == 0xE1 && bytes.HasPrefix(dat, []byte("Exif\x00\x00")) {
+			ans.Created = parseExifDate(dat[6:])
+			return nil
+		}
+	}
+}
+
+// Read IFD entries of a TIFF structure, by tag.
+func readIFD(tif []byte, off uint32, bo binary.ByteOrder) map[uint16][]byte {
+	var ans = map[uint16][]byte{}
+	if int(off)+2 > len(tif) {
+		return ans
+	}
+	var n = int(bo.Uint16(tif[off:]))
+	for i := 0; i < n; i++ {
+		var e = int(off) + 2 + i*12
+		if e+12 > len(tif) {
+			break
+		}
+		ans[bo.Uint16(tif[e:])] = tif[e+2 : e+12]
+	}
+	return ans
+}
+
+// Get ASCII value of an IFD entry.
+func ifdString(tif []byte, ent []byte, bo binary.ByteOrder) string {
+	var cnt = bo.Uint32(ent[2:6])
+	var val = ent[6:10]
+	if cnt > 4 {
+		var off = bo.Uint32(ent[6:10])
+		if uint64(off)+uint64(cnt) > uint64(len(tif)) {
+			return ""
+		}
+		val = tif[off : off+cnt]
+	} else {
+		val = val[:cnt]
+	}
+	return strings.TrimRight(string(val), "\x00 ")
+}
+
+// Parse original date of EXIF data (in local time).
+func parseExifDate(tif []byte) time.Time {
+	var bo binary.ByteOrder = binary.LittleEndian
+	if len(tif) < 8 {
+		return time.Time{}
+	}
+	if string(tif[:2]) == "MM" {
+		bo = binary.BigEndian
+	}
+	var ifd0 = readIFD(tif, bo.Uint32(tif[4:]), bo)
+	var txt string
+	if ptr, ok := ifd0[0x8769]; ok {
+		var exif = readIFD(tif, bo.Uint32(ptr[6:]), bo)
+		if ent, ok := exif[0x9003]; ok {
+			txt = ifdString(tif, ent, bo)
+		}
+	}
+	if ent, ok := ifd0[0x0132]; ok && txt == "" {
+		txt = ifdString(tif, ent, bo)
+	}
+	t, _ := time.ParseInLocation("2006:01:02 15:04:05", txt, time.Local)
+	return t
+}
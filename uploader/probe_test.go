@@ -0,0 +1,211 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Build an MP4 box.
+func mp4Box(typ string, body ...[]byte) []byte {
+	var dat = bytes.Join(body, nil)
+	var ans = make([]byte, 8, 8+len(dat))
+	binary.BigEndian.PutUint32(ans, uint32(8+len(dat)))
+	copy(ans[4:], typ)
+	return append(ans, dat...)
+}
+
+// Build an EBML element, with an 8 byte size.
+func ebml(id uint32, body ...[]byte) []byte {
+	var dat = bytes.Join(body, nil)
+	var ans []byte
+	for s := 24; s >= 0; s -= 8 {
+		if b := byte(id >> uint(s)); b != 0 || len(ans) > 0 {
+			ans = append(ans, b)
+		}
+	}
+	var siz [8]byte
+	binary.BigEndian.PutUint64(siz[:], uint64(len(dat)))
+	siz[0] = 0x01
+	return append(append(ans, siz[:]...), dat...)
+}
+
+func be32(n uint32) []byte {
+	var ans = make([]byte, 4)
+	binary.BigEndian.PutUint32(ans, n)
+	return ans
+}
+
+func probeBytes(t *testing.T, nam string, dat []byte) MediaInfo {
+	var pth = filepath.Join(t.TempDir(), nam)
+	if err := ioutil.WriteFile(pth, dat, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ans, err := Probe(pth)
+	if err != nil {
+		t.Fatalf("Probe(%s): %v", nam, err)
+	}
+	return ans
+}
+
+func TestProbeMP4(t *testing.T) {
+	var created = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var mvhd = bytes.Join([][]byte{
+		be32(0), be32(uint32(created.Sub(mp4Epoch) / time.Second)), be32(0), be32(1000), be32(90500),
+	}, nil)
+	var tkhd = make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], 1920<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], 1080<<16)
+	var track = func(handler, codec string) []byte {
+		return mp4Box("trak", mp4Box("tkhd", tkhd), mp4Box("mdia",
+			mp4Box("hdlr", be32(0), be32(0), []byte(handler)),
+			mp4Box("minf", mp4Box("stbl", mp4Box("stsd", be32(0), be32(1), be32(16), []byte(codec)))),
+		))
+	}
+	var dat = bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom"), be32(0)),
+		mp4Box("mdat", make([]byte, 100)),
+		mp4Box("moov",
+			mp4Box("mvhd", mvhd),
+			track("soun", "mp4a"),
+			track("vide", "avc1"),
+			mp4Box("udta", mp4Box("\xa9xyz", []byte("\x00\x12\x15\xc7+48.8584+002.2945/"))),
+		),
+	}, nil)
+	var info = probeBytes(t, "v.mp4", dat)
+	if info.Format != "mp4" || info.VideoCodec != "avc1" || info.AudioCodec != "mp4a" {
+		t.Errorf("format, codecs = %q, %q, %q", info.Format, info.VideoCodec, info.AudioCodec)
+	}
+	if info.Width != 1920 || info.Height != 1080 {
+		t.Errorf("size = %dx%d, want 1920x1080", info.Width, info.Height)
+	}
+	if info.Duration != 90500*time.Millisecond {
+		t.Errorf("duration = %v, want 1m30.5s", info.Duration)
+	}
+	if !info.Created.Equal(created) {
+		t.Errorf("created = %v, want %v", info.Created, created)
+	}
+	if l := info.Location; l == nil || l.Latitude != 48.8584 || l.Longitude != 2.2945 {
+		t.Errorf("location = %+v, want 48.8584,2.2945", l)
+	}
+}
+
+func TestProbeMKV(t *testing.T) {
+	var created = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	var dur = make([]byte, 8)
+	binary.BigEndian.PutUint64(dur, math.Float64bits(5000))
+	var date = make([]byte, 8)
+	binary.BigEndian.PutUint64(date, uint64(created.Sub(mkvEpoch)))
+	var dat = bytes.Join([][]byte{
+		ebml(mkvEBML, ebml(mkvDocType, []byte("webm"))),
+		// segment of unknown size
+		{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		ebml(0xEC, make([]byte, 10)),
+		ebml(mkvInfo,
+			ebml(mkvTimecodeScale, []byte{0x0F, 0x42, 0x40}),
+			ebml(mkvDuration, dur),
+			ebml(mkvDateUTC, date),
+		),
+		ebml(mkvTracks,
+			ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{2}), ebml(mkvCodecID, []byte("A_OPUS"))),
+			ebml(mkvTrackEntry, ebml(mkvTrackType, []byte{1}), ebml(mkvCodecID, []byte("V_VP9")),
+				ebml(mkvVideo, ebml(mkvPixelWidth, []byte{0x05, 0x00}), ebml(mkvPixelHeight, []byte{0x02, 0xD0})),
+			),
+		),
+	}, nil)
+	var info = probeBytes(t, "v.webm", dat)
+	if info.Format != "webm" || info.VideoCodec != "V_VP9" || info.AudioCodec != "A_OPUS" {
+		t.Errorf("format, codecs = %q, %q, %q", info.Format, info.VideoCodec, info.AudioCodec)
+	}
+	if info.Width != 1280 || info.Height != 720 {
+		t.Errorf("size = %dx%d, want 1280x720", info.Width, info.Height)
+	}
+	if info.Duration != 5*time.Second {
+		t.Errorf("duration = %v, want 5s", info.Duration)
+	}
+	if !info.Created.Equal(created) {
+		t.Errorf("created = %v, want %v", info.Created, created)
+	}
+}
+
+func TestProbeJPEG(t *testing.T) {
+	var le = func(n ...uint32) []byte {
+		var ans []byte
+		for _, v := range n {
+			ans = append(ans, byte(v), byte(v>>8))
+		}
+		return ans
+	}
+	// TIFF header, IFD0 pointing to EXIF IFD at 26, date string at 44
+	var tif = bytes.Join([][]byte{
+		[]byte("II*\x00"), le(8, 0),
+		le(1), le(0x8769, 4, 1, 0, 26, 0), le(0, 0),
+		le(1), le(0x9003, 2, 20, 0, 44, 0), le(0, 0),
+		[]byte("2021:06:05 14:30:00\x00"),
+	}, nil)
+	var app1 = append([]byte("Exif\x00\x00"), tif...)
+	var dat = bytes.Join([][]byte{
+		{0xFF, 0xD8},
+		{0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00},
+		{0xFF, 0xE1, byte((len(app1) + 2) >> 8), byte(len(app1) + 2)}, app1,
+		{0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9},
+	}, nil)
+	var info = probeBytes(t, "p.jpg", dat)
+	var want = time.Date(2021, 6, 5, 14, 30, 0, 0, time.Local)
+	if info.Format != "jpeg" || !info.Created.Equal(want) {
+		t.Errorf("format, created = %q, %v, want jpeg, %v", info.Format, info.Created, want)
+	}
+}
+
+func TestProbeUnknown(t *testing.T) {
+	var info = probeBytes(t, "v.avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"))
+	if info != (MediaInfo{}) {
+		t.Errorf("info = %+v, want empty", info)
+	}
+}
+
+func TestReadVint(t *testing.T) {
+	var tests = []struct {
+		dat  []byte
+		id   bool
+		want int64
+		n    int
+	}{
+		{[]byte{0x81}, false, 1, 1},
+		{[]byte{0x40, 0x02}, false, 2, 2},
+		{[]byte{0xFF}, false, mkvUnknownSize, 1},
+		{[]byte{0x1A, 0x45, 0xDF, 0xA3}, true, mkvEBML, 4},
+	}
+	for _, tt := range tests {
+		got, n, err := readVint(bytes.NewReader(tt.dat), tt.id)
+		if err != nil || got != tt.want || n != tt.n {
+			t.Errorf("readVint(%x) = %d, %d, %v, want %d, %d", tt.dat, got, n, err, tt.want, tt.n)
+		}
+	}
+	if _, _, err := readVint(bytes.NewReader([]byte{0x00}), false); err == nil {
+		t.Errorf("readVint(00) should fail")
+	}
+}
+
+func TestParseISO6709(t *testing.T) {
+	var tests = []struct {
+		txt           string
+		lat, lng, alt float64
+	}{
+		{"+48.8584+002.2945/", 48.8584, 2.2945, 0},
+		{"-33.8568+151.2153+010.500/", -33.8568, 151.2153, 10.5},
+	}
+	for _, tt := range tests {
+		got := parseISO6709(tt.txt)
+		if got == nil || got.Latitude != tt.lat || got.Longitude != tt.lng || got.Altitude != tt.alt {
+			t.Errorf("parseISO6709(%q) = %+v", tt.txt, got)
+		}
+	}
+	if got := parseISO6709("paris"); got != nil {
+		t.Errorf("parseISO6709(paris) = %+v, want nil", got)
+	}
+}
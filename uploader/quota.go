@@ -0,0 +1,165 @@
+package uploader
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultQuota is the daily quota of a project, in units.
+const DefaultQuota = 10000
+
+// QuotaCosts are estimated units of each API call, by "METHOD resource".
+var QuotaCosts = map[string]int64{
+	"GET videos":          1,
+	"POST videos":         1600,
+	"PUT videos":          50,
+	"DELETE videos":       50,
+	"POST thumbnails/set": 50,
+	"GET captions":        50,
+	"POST captions":       400,
+	"PUT captions":        450,
+	"DELETE captions":     50,
+	"GET playlists":       1,
+	"POST playlists":      50,
+	"PUT playlists":       50,
+	"GET playlistItems":   1,
+	"POST playlistItems":  50,
+	"GET search":          100,
+	"GET channels":        1,
+}
+
+// Quota resets at midnight Pacific time.
+var quotaLocation = loadQuotaLocation()
+
+func loadQuotaLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*60*60)
+	}
+	return loc
+}
+
+// RequestCost gets estimated units of an API request.
+// Chunks of a resumable upload are free, the session start is charged.
+func RequestCost(r *http.Request) int64 {
+	var i = strings.Index(r.URL.Path, "youtube/v3/")
+	if i < 0 || r.URL.Query().Get("upload_id") != "" {
+		return 0
+	}
+	return QuotaCosts[r.Method+" "+r.URL.Path[i+len("youtube/v3/"):]]
+}
+
+// QuotaLedger records units used today by each credential, in a file.
+type QuotaLedger struct {
+	Day   string           `json:"day"`
+	Units map[string]int64 `json:"used"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// LoadQuotaLedger loads a ledger file, which may not exist yet.
+func LoadQuotaLedger(pth string) (*QuotaLedger, error) {
+	var l = &QuotaLedger{path: pth}
+	dat, err := ioutil.ReadFile(pth)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err = json.Unmarshal(dat, l); err != nil {
+			return nil, err
+		}
+	}
+	l.reset(time.Now())
+	return l, nil
+}
+
+// Reset usage if day has changed.
+func (l *QuotaLedger) reset(now time.Time) {
+	var day = now.In(quotaLocation).Format("2006-01-02")
+	if l.Day != day || l.Units == nil {
+		l.Day = day
+		l.Units = map[string]int64{}
+	}
+}
+
+func (l *QuotaLedger) save() error {
+	if l.path == "" {
+		return nil
+	}
+	dat, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.path, dat, 0600)
+}
+
+// Used gets units used today by a credential.
+func (l *QuotaLedger) Used(key string) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reset(time.Now())
+	return l.Units[key]
+}
+
+// Add records units used by a credential.
+func (l *QuotaLedger) Add(key string, units int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reset(time.Now())
+	l.Units[key] += units
+	return l.save()
+}
+
+// Exhaust marks a credential as out of quota for today.
+func (l *QuotaLedger) Exhaust(key string, limit int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reset(time.Now())
+	if l.Units[key] < limit {
+		l.Units[key] = limit
+	}
+	return l.save()
+}
+
+// QuotaTransport records estimated units of each request in a ledger,
+// against the current credential.
+type QuotaTransport struct {
+	Base   http.RoundTripper
+	Ledger *QuotaLedger
+
+	mu  sync.Mutex
+	key string
+}
+
+// SetKey sets the credential to charge requests to.
+func (t *QuotaTransport) SetKey(key string) {
+	t.mu.Lock()
+	t.key = key
+	t.mu.Unlock()
+}
+
+// RoundTrip sends a request, and records its cost.
+func (t *QuotaTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var base = t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	res, err := base.RoundTrip(r)
+	if err == nil && t.Ledger != nil {
+		t.mu.Lock()
+		var key = t.key
+		t.mu.Unlock()
+		if cost := RequestCost(r); cost > 0 {
+			if err := t.Ledger.Add(key, cost); err != nil {
+				Logf("Error saving quota ledger: %v\n", err)
+			}
+		}
+	}
+	return res, err
+}
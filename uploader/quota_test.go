@@ -0,0 +1,60 @@
+package uploader
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuotaLedgerReset(t *testing.T) {
+	// 23:59 PST, UTC day has already changed
+	var before = time.Date(2024, 1, 16, 7, 59, 0, 0, time.UTC)
+	var l = &QuotaLedger{}
+	l.reset(before)
+	l.Units["a"] = 100
+	l.reset(before)
+	if l.Day != "2024-01-15" || l.Units["a"] != 100 {
+		t.Errorf("ledger at 23:59 PST = %s, %v, want 2024-01-15 kept", l.Day, l.Units)
+	}
+	l.reset(before.Add(time.Minute))
+	if l.Day != "2024-01-16" || len(l.Units) != 0 {
+		t.Errorf("ledger at 00:00 PST = %s, %v, want 2024-01-16 reset", l.Day, l.Units)
+	}
+}
+
+func TestQuotaLedger(t *testing.T) {
+	var pth = filepath.Join(t.TempDir(), "quota.json")
+	l, err := LoadQuotaLedger(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Add("a", 1600)
+	l.Add("a", 50)
+	l.Exhaust("b", 10000)
+	l.Exhaust("a", 1000)
+	if l, err = LoadQuotaLedger(pth); err != nil {
+		t.Fatal(err)
+	}
+	if l.Used("a") != 1650 || l.Used("b") != 10000 {
+		t.Errorf("used = %d, %d, want 1650, 10000", l.Used("a"), l.Used("b"))
+	}
+}
+
+func TestRequestCost(t *testing.T) {
+	var tests = []struct {
+		method, url string
+		want        int64
+	}{
+		{"POST", "https://www.googleapis.com/upload/youtube/v3/videos?uploadType=resumable", 1600},
+		{"PUT", "https://www.googleapis.com/upload/youtube/v3/videos?uploadType=resumable&upload_id=x", 0},
+		{"GET", "https://www.googleapis.com/youtube/v3/videos?part=snippet", 1},
+		{"GET", "https://www.googleapis.com/youtube/v3/search", 100},
+		{"GET", "https://example.com/other", 0},
+	}
+	for _, tt := range tests {
+		if got := RequestCost(httptest.NewRequest(tt.method, tt.url, nil)); got != tt.want {
+			t.Errorf("RequestCost(%s %s) = %d, want %d", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Default resumable chunk size (16MB), chunks must be multiples of 256KB.
+const defUploadChunk = 16 * 1024 * 1024
+const minUploadChunk = 256 * 1024
+
+// Chunks sent in a row without the session committing any of them.
+const maxStalledChunks = 5
+
+// StateDir keeps upload state of video URLs, the current directory by default.
+var StateDir = ""
+
+// resumeState is saved next to the video (or in StateDir for a URL) while an
+// upload is in progress.
+type resumeState struct {
+	Video   string `json:"video"`
+	Session string `json:"session"`
+	Offset  int64  `json:"offset"`
+	Size    int64  `json:"size"`
+}
+
+// Get state file path for a video file or URL (by its hash).
+func resumeStatePath(nam string) string {
+	if strings.HasPrefix(nam, "http") {
+		return filepath.Join(StateDir, fmt.Sprintf("%x.upload.json", sha256.Sum256([]byte(nam))))
+	}
+	return nam + ".upload.json"
+}
+
+func loadResumeState(pth string) (resumeState, error) {
+	var s resumeState
+	dat, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(dat, &s)
+	return s, err
+}
+
+func saveResumeState(pth string, s resumeState) error {
+	dat, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(pth, dat, 0600)
+}
+
+// Get upload URL for a resource, from service base path.
+func apiUploadURL(srv *youtube.Service, res string) string {
+	base := strings.TrimSuffix(srv.BasePath, "youtube/v3/")
+	return base + "upload/youtube/v3/" + res
+}
+
+func videoContentType(nam string) string {
+	var typ = mime.TypeByExtension(filepath.Ext(nam))
+	if strings.HasPrefix(typ, "video/") {
+		return typ
+	}
+	return "video/*"
+}
+
+// Round chunk size up to a multiple of 256KB.
+func resumeChunkSize(cnk int) int64 {
+	if cnk <= 0 {
+		return defUploadChunk
+	}
+	return int64((cnk + minUploadChunk - 1) / minUploadChunk * minUploadChunk)
+}
+
+// Parse "Range: bytes=0-N" into committed byte count.
+func parseCommittedRange(rng string) (int64, error) {
+	if rng == "" {
+		return 0, nil
+	}
+	var i = strings.LastIndex(rng, "-")
+	if i < 0 {
+		return 0, fmt.Errorf("invalid range %q", rng)
+	}
+	end, err := strconv.ParseInt(rng[i+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid range %q", rng)
+	}
+	return end + 1, nil
+}
+
+// Start a new resumable upload session, and return its URI.
+func startResumableSession(ctx context.Context, cli *http.Client, srv *youtube.Service, obj *youtube.Video, typ string, siz int64) (string, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	var u = apiUploadURL(srv, "videos") + "?uploadType=resumable&part=" + strings.Join(VideoParts(obj), ",")
+	req, err := http.NewRequest("POST", u, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", typ)
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(siz, 10))
+	res, err := cli.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if err = googleapi.CheckResponse(res); err != nil {
+		return "", wrapError("starting resumable upload", err)
+	}
+	var loc = res.Header.Get("Location")
+	if loc == "" {
+		return "", wrapError("starting resumable upload", fmt.Errorf("no session URI returned"))
+	}
+	return loc, nil
+}
+
+// Send a chunk (or a status query if dat is nil) to an upload session.
+// Returns the committed byte count, or the video once upload is complete.
+func putResumableChunk(ctx context.Context, cli *http.Client, s resumeState, typ string, dat []byte) (int64, *youtube.Video, error) {
+	req, err := http.NewRequest("PUT", s.Session, bytes.NewReader(dat))
+	if err != nil {
+		return 0, nil, err
+	}
+	req = req.WithContext(ctx)
+	if dat == nil {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", s.Size))
+	} else {
+		req.Header.Set("Content-Type", typ)
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", s.Offset, s.Offset+int64(len(dat))-1, s.Size))
+	}
+	req.ContentLength = int64(len(dat))
+	res, err := cli.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		var v = &youtube.Video{}
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			return 0, nil, err
+		}
+		return s.Size, v, nil
+	case 308:
+		off, err := parseCommittedRange(res.Header.Get("Range"))
+		return off, nil, err
+	case http.StatusNotFound, http.StatusGone:
+		return 0, nil, &Error{Op: "uploading chunk", Kind: ErrSessionExpired, Err: googleapi.CheckResponse(res)}
+	}
+	return 0, nil, wrapError("uploading chunk", googleapi.CheckResponse(res))
+}
+
+// uploadVideoSession uploads video through a resumable session, saving its state after every
+// chunk. If resume is set, a saved session is continued.
+func uploadVideoSession(ctx context.Context, cli *http.Client, srv *youtube.Service, nam string, fil io.ReadCloser, siz int64, obj *youtube.Video, cnk int, resume bool) (*youtube.Video, error) {
+	var pth = resumeStatePath(nam)
+	var typ = videoContentType(nam)
+	var s resumeState
+	if resume {
+		old, err := loadResumeState(pth)
+		if err == nil && old.Size == siz && old.Session != "" {
+			off, v, err := putResumableChunk(ctx, cli, old, typ, nil)
+			if v != nil {
+				os.Remove(pth)
+				return v, nil
+			}
+			if err == nil {
+				s = old
+				s.Offset = off
+				Logf("Resuming upload at %d / %d bytes...\n", off, siz)
+			} else {
+				Logf("Cannot resume upload (%v), starting over...\n", err)
+			}
+		} else if err == nil {
+			Logf("Upload state '%s' does not match video, starting over...\n", pth)
+		}
+	}
+	if s.Session == "" {
+		ses, err := startResumableSession(ctx, cli, srv, obj, typ, siz)
+		if err != nil {
+			return nil, err
+		}
+		s = resumeState{Video: nam, Session: ses, Size: siz}
+	}
+	if err := saveResumeState(pth, s); err != nil {
+		return nil, err
+	}
+	if s.Offset > 0 {
+		fil.Close()
+		var err error
+		if fil, err = OpenAt(nam, s.Offset); err != nil {
+			return nil, err
+		}
+		defer fil.Close()
+	}
+	var max = resumeChunkSize(cnk)
+	var buf []byte
+	var stalled int
+	for {
+		// fill pending bytes upto chunk size
+		if need := max - int64(len(buf)); need > 0 && s.Offset+int64(len(buf)) < s.Size {
+			dat, err := ioutil.ReadAll(io.LimitReader(fil, need))
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, dat...)
+		}
+		if len(buf) == 0 {
+			buf = nil
+		}
+		off, v, err := putResumableChunk(ctx, cli, s, typ, buf)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			os.Remove(pth)
+			return v, nil
+		}
+		if off < s.Offset || off > s.Offset+int64(len(buf)) {
+			return nil, fmt.Errorf("upload session committed unexpected range: %d", off)
+		}
+		if off == s.Offset && len(buf) == 0 {
+			return nil, fmt.Errorf("upload session did not complete at %d / %d bytes", off, s.Size)
+		}
+		if off > s.Offset {
+			stalled = 0
+		} else if stalled++; stalled >= maxStalledChunks {
+			return nil, fmt.Errorf("upload session committed nothing of %d chunks at %d / %d bytes", stalled, off, s.Size)
+		}
+		buf = buf[off-s.Offset:]
+		s.Offset = off
+		if err := saveResumeState(pth, s); err != nil {
+			return nil, err
+		}
+	}
+}
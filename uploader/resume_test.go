@@ -0,0 +1,121 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golangf/youtubeuploader/fakeyt"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Record chunks sent to upload sessions, failing one of them.
+type breakingServer struct {
+	fake   *fakeyt.Server
+	ranges []string
+	fail   int
+}
+
+func (s *breakingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var rng = r.Header.Get("Content-Range")
+	if r.Method == "PUT" && rng != "" && !strings.HasPrefix(rng, "bytes */") {
+		if s.ranges = append(s.ranges, rng); len(s.ranges) == s.fail {
+			http.Error(w, "connection lost", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	s.fake.ServeHTTP(w, r)
+}
+
+func newSessionTest(t *testing.T, h http.Handler, siz int) (*youtube.Service, string) {
+	var srv = httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	service, err := youtube.New(&http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = srv.URL + "/"
+	var pth = filepath.Join(t.TempDir(), "v.mp4")
+	if err = ioutil.WriteFile(pth, bytes.Repeat([]byte("v"), siz), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return service, pth
+}
+
+func uploadTestSession(service *youtube.Service, pth string, resume bool) (*youtube.Video, error) {
+	fil, err := os.Open(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer fil.Close()
+	fi, _ := fil.Stat()
+	var obj = &youtube.Video{Snippet: &youtube.VideoSnippet{Title: "a"}}
+	return uploadVideoSession(context.Background(), &http.Client{}, service, pth, fil, fi.Size(), obj, minUploadChunk, resume)
+}
+
+func TestResumeSession(t *testing.T) {
+	var fake = fakeyt.New()
+	var bs = &breakingServer{fake: fake, fail: 2}
+	var siz = 2*minUploadChunk + 1000
+	service, pth := newSessionTest(t, bs, siz)
+	if _, err := uploadTestSession(service, pth, false); err == nil {
+		t.Fatalf("upload should fail at second chunk")
+	}
+	s, err := loadResumeState(resumeStatePath(pth))
+	if err != nil || s.Offset != minUploadChunk {
+		t.Fatalf("state = %+v, %v, want offset %d", s, err, minUploadChunk)
+	}
+	// continued at committed offset, not from start
+	bs.ranges, bs.fail = nil, 0
+	video, err := uploadTestSession(service, pth, true)
+	if err != nil {
+		t.Fatalf("resumed upload: %v", err)
+	}
+	if len(bs.ranges) != 2 || !strings.HasPrefix(bs.ranges[0], "bytes 262144-524287/") {
+		t.Errorf("resumed chunks = %v, want from 262144", bs.ranges)
+	}
+	if _, size := fake.Video(video.Id); size != int64(siz) {
+		t.Errorf("uploaded %d bytes, want %d", size, siz)
+	}
+	if _, err = os.Stat(resumeStatePath(pth)); !os.IsNotExist(err) {
+		t.Errorf("state file kept after upload: %v", err)
+	}
+}
+
+func TestResumeSessionStalled(t *testing.T) {
+	var chunks int
+	var h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.Header().Set("Location", "http://"+r.Host+r.URL.Path+"?upload_id=1")
+			return
+		}
+		// nothing committed ever
+		chunks++
+		w.WriteHeader(308)
+	})
+	service, pth := newSessionTest(t, h, 1000)
+	if _, err := uploadTestSession(service, pth, false); err == nil {
+		t.Fatalf("upload should give up without progress")
+	}
+	if chunks != maxStalledChunks {
+		t.Errorf("sent %d chunks, want %d", chunks, maxStalledChunks)
+	}
+}
+
+func TestResumeStatePath(t *testing.T) {
+	StateDir = "creds"
+	defer func() { StateDir = "" }()
+	if got := resumeStatePath("dir/v.mp4"); got != "dir/v.mp4.upload.json" {
+		t.Errorf("resumeStatePath(file) = %q", got)
+	}
+	var a, b = resumeStatePath("https://example.com/a/video.mp4"), resumeStatePath("https://example.com/b/video.mp4")
+	if a == b || filepath.Dir(a) != "creds" || !strings.HasSuffix(a, ".upload.json") {
+		t.Errorf("resumeStatePath(URL) = %q, %q, want distinct files in creds", a, b)
+	}
+}
@@ -0,0 +1,132 @@
+package uploader
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Backoff limits
+const minBackoff = time.Second
+const maxBackoff = 64 * time.Second
+
+// RetryTransport retries requests on 5xx, 429 and network errors, with
+// exponential backoff and jitter. It honours Retry-After, and never
+// retries when quota is exceeded.
+type RetryTransport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+	// Budget is the total wait time for all retries, 0 for no limit.
+	Budget time.Duration
+
+	mu    sync.Mutex
+	spent time.Duration
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// Spend wait time from budget, if possible.
+func (t *RetryTransport) spend(d time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Budget > 0 && t.spent+d > t.Budget {
+		return false
+	}
+	t.spent += d
+	return true
+}
+
+// Get wait time before a retry.
+func backoff(i int, res *http.Response) time.Duration {
+	if res != nil {
+		if d, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			return d
+		}
+	}
+	var d = minBackoff << uint(i)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	// jitter in [d/2, d)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// Parse Retry-After as seconds or HTTP date.
+func parseRetryAfter(txt string) (time.Duration, bool) {
+	if txt == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(txt); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(txt); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Check if a response should be retried.
+func shouldRetry(res *http.Response) bool {
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+		return false
+	}
+	// peek body for quota errors, and restore it
+	dat, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(dat))
+	if err != nil {
+		return true
+	}
+	return !bytes.Contains(dat, []byte("quotaExceeded")) && !bytes.Contains(dat, []byte("dailyLimitExceeded"))
+}
+
+// RoundTrip sends a request, retrying if needed. Requests with a body
+// that cannot be replayed, or canceled, are not retried.
+func (t *RetryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var replay = r.Body == nil || r.Body == http.NoBody || r.GetBody != nil
+	for i := 0; ; i++ {
+		var req = r
+		if i > 0 && r.GetBody != nil {
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = r.Clone(r.Context())
+			req.Body = body
+		}
+		res, err := t.base().RoundTrip(req)
+		if err == nil && !shouldRetry(res) {
+			return res, nil
+		}
+		if !replay || i >= t.MaxRetries || r.Context().Err() != nil {
+			return res, err
+		}
+		var d = backoff(i, res)
+		if !t.spend(d) {
+			return res, err
+		}
+		if err != nil {
+			Logf("Retrying %s %s in %v: %v\n", r.Method, r.URL.Path, d, err)
+		} else {
+			Logf("Retrying %s %s in %v: %v\n", r.Method, r.URL.Path, d, res.Status)
+			res.Body.Close()
+		}
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package uploader
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golangf/youtubeuploader/fakeyt"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Fail the first request of each media upload, with 503.
+type flakyServer struct {
+	fake   *fakeyt.Server
+	failed map[string]bool
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/upload/") && !s.failed[r.URL.Path] {
+		s.failed[r.URL.Path] = true
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "backend error", http.StatusServiceUnavailable)
+		return
+	}
+	s.fake.ServeHTTP(w, r)
+}
+
+func TestRetryMedia(t *testing.T) {
+	var fake = fakeyt.New()
+	var srv = httptest.NewServer(&flakyServer{fake: fake, failed: map[string]bool{}})
+	defer srv.Close()
+	service, err := youtube.New(&http.Client{Transport: &RetryTransport{MaxRetries: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.BasePath = srv.URL + "/"
+	video, err := UploadVideo(service, bytes.NewReader([]byte("video")), &youtube.Video{Snippet: &youtube.VideoSnippet{Title: "a"}}, 0)
+	if err == nil {
+		t.Fatalf("streamed video upload was retried, as %s", video.Id)
+	}
+	video, err = UploadVideo(service, bytes.NewReader([]byte("video")), &youtube.Video{Snippet: &youtube.VideoSnippet{Title: "a"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var thumb = []byte("\xff\xd8\xff\xe0thumbnail")
+	if err = UploadThumbnail(service, video.Id, bytes.NewReader(thumb)); err != nil {
+		t.Fatalf("UploadThumbnail: %v", err)
+	}
+	if got := fake.Thumbnail(video.Id); !bytes.Equal(got, thumb) {
+		t.Errorf("thumbnail = %q, want %q", got, thumb)
+	}
+	var track = CaptionTrack{Language: "en", Name: "en"}
+	if _, err = UploadCaptionTrack(service, video.Id, track, strings.NewReader("1\n00:00:00,000 --> 00:00:01,000\nHi\n")); err != nil {
+		t.Fatalf("UploadCaptionTrack: %v", err)
+	}
+	if got := fake.Captions(video.Id); len(got) != 1 {
+		t.Errorf("captions = %v, want 1 track", got)
+	}
+}
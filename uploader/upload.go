@@ -0,0 +1,228 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// Logf logs progress messages, it does nothing by default.
+var Logf = func(msg string, a ...interface{}) {}
+
+// VideoParts gets the parts to send for a video, with localizations if any.
+func VideoParts(obj *youtube.Video) []string {
+	var ans = []string{"snippet", "status", "recordingDetails"}
+	if len(obj.Localizations) > 0 {
+		ans = append(ans, "localizations")
+	}
+	return ans
+}
+
+// UpdateVideo updates snippet, status, recording details and localizations
+// of a video.
+func UpdateVideo(srv *youtube.Service, id string, obj *youtube.Video) error {
+	obj.Id = id
+	_, err := srv.Videos.Update(VideoParts(obj), obj).Do()
+	if err != nil {
+		return wrapError("updating video", err)
+	}
+	return nil
+}
+
+// GetVideo gets the given parts of a video.
+func GetVideo(srv *youtube.Service, id string, parts []string) (*youtube.Video, error) {
+	res, err := srv.Videos.List(parts).Id(id).Do()
+	if err != nil {
+		return nil, wrapError("getting video", err)
+	}
+	if len(res.Items) == 0 {
+		return nil, &Error{Op: "getting video", Kind: ErrVideoNotFound, Err: fmt.Errorf("Video ID '%s' doesn't exist", id)}
+	}
+	return res.Items[0], nil
+}
+
+// UpdateVideoParts updates the given parts of a video, leaving others as is.
+func UpdateVideoParts(srv *youtube.Service, obj *youtube.Video, parts []string) (*youtube.Video, error) {
+	res, err := srv.Videos.Update(parts, obj).Do()
+	if err != nil {
+		return nil, wrapError("updating video", err)
+	}
+	return res, nil
+}
+
+// UpdateVideoIfUnchanged updates the given parts of a video fetched with
+// other parts, refusing if its etag has changed since. The etag depends on
+// parts, so it is fetched again with the same.
+func UpdateVideoIfUnchanged(srv *youtube.Service, obj *youtube.Video, fetched []string, parts []string) (*youtube.Video, error) {
+	cur, err := GetVideo(srv, obj.Id, fetched)
+	if err != nil {
+		return nil, err
+	}
+	if cur.Etag != obj.Etag {
+		return nil, &Error{Op: "updating video", Kind: ErrVideoChanged, Err: fmt.Errorf("Video ID '%s' has changed since it was fetched", obj.Id)}
+	}
+	return UpdateVideoParts(srv, obj, parts)
+}
+
+// Read small media (thumbnail, caption) in memory, sent in a single
+// request, so that it can be retried. Its content type is set, as one
+// detected by the API client would wrap the reader.
+func readMedia(fil io.Reader) (io.Reader, []googleapi.MediaOption, error) {
+	dat, err := ioutil.ReadAll(fil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var opts = []googleapi.MediaOption{googleapi.ChunkSize(0), googleapi.ContentType(http.DetectContentType(dat))}
+	return bytes.NewReader(dat), opts, nil
+}
+
+// UploadVideo uploads a video, in a single request if chunk size is 0. It
+// is streamed, so failed requests are not retried.
+func UploadVideo(srv *youtube.Service, fil io.Reader, obj *youtube.Video, cnk int) (*youtube.Video, error) {
+	opt := googleapi.ChunkSize(cnk)
+	req := srv.Videos.Insert(VideoParts(obj), obj)
+	res, err := req.Media(fil, opt).Do()
+	if err != nil {
+		return nil, wrapError("making YouTube API call", err)
+	}
+	return res, nil
+}
+
+// UploadVideoResumable uploads a video through a resumable session, which
+// can be continued with resume after an interruption.
+func UploadVideoResumable(ctx context.Context, cli *http.Client, srv *youtube.Service, nam string, fil io.ReadCloser, siz int64, obj *youtube.Video, cnk int, resume bool) (*youtube.Video, error) {
+	res, err := uploadVideoSession(ctx, cli, srv, nam, fil, siz, obj, cnk, resume)
+	if err != nil {
+		return nil, wrapError("making YouTube API call", err)
+	}
+	return res, nil
+}
+
+// UploadThumbnail sets the thumbnail of a video.
+func UploadThumbnail(srv *youtube.Service, id string, fil io.Reader) error {
+	med, opts, err := readMedia(fil)
+	if err != nil {
+		return wrapError("reading thumbnail", err)
+	}
+	_, err = srv.Thumbnails.Set(id).Media(med, opts...).Do()
+	if err != nil {
+		return wrapError("uploading thumbnail", err)
+	}
+	return nil
+}
+
+// UploadCaption uploads a caption track of a video.
+func UploadCaption(srv *youtube.Service, id string, lng string, fil io.Reader) error {
+	_, err := UploadCaptionTrack(srv, id, CaptionTrack{Language: lng, Name: lng, Sync: true}, fil)
+	return err
+}
+
+// CaptionTrack identifies a caption track of a video, by language and name.
+type CaptionTrack struct {
+	Language string
+	Name     string
+	Draft    bool
+	// Sync has YouTube replace timings using speech recognition.
+	Sync bool
+}
+
+// ListCaptions returns caption tracks of a video.
+func ListCaptions(srv *youtube.Service, id string) ([]*youtube.Caption, error) {
+	res, err := srv.Captions.List([]string{"snippet"}, id).Do()
+	if err != nil {
+		return nil, wrapError("listing captions", err)
+	}
+	return res.Items, nil
+}
+
+// UploadCaptionTrack uploads a caption track of a video, updating the track
+// with same language and name if it exists. Returns true if updated.
+func UploadCaptionTrack(srv *youtube.Service, id string, t CaptionTrack, fil io.Reader) (bool, error) {
+	med, opts, err := readMedia(fil)
+	if err != nil {
+		return false, wrapError("reading caption", err)
+	}
+	res, err := srv.Captions.List([]string{"snippet"}, id).Do()
+	if err != nil {
+		return false, wrapError("listing captions", err)
+	}
+	for _, o := range res.Items {
+		if o.Snippet.Language != t.Language || o.Snippet.Name != t.Name {
+			continue
+		}
+		c := &youtube.Caption{Id: o.Id, Snippet: &youtube.CaptionSnippet{IsDraft: t.Draft}}
+		c.Snippet.ForceSendFields = []string{"IsDraft"}
+		req := srv.Captions.Update([]string{"snippet"}, c).Sync(t.Sync)
+		if _, err = req.Media(med, opts...).Do(); err != nil {
+			return true, wrapError("updating caption", err)
+		}
+		return true, nil
+	}
+	c := &youtube.Caption{
+		Snippet: &youtube.CaptionSnippet{},
+	}
+	c.Snippet.VideoId = id
+	c.Snippet.Language = t.Language
+	c.Snippet.Name = t.Name
+	c.Snippet.IsDraft = t.Draft
+	req := srv.Captions.Insert([]string{"snippet"}, c).Sync(t.Sync)
+	if _, err = req.Media(med, opts...).Do(); err != nil {
+		return false, wrapError("uploading caption", err)
+	}
+	return false, nil
+}
+
+// AddToPlaylistID adds a video to a playlist id.
+func AddToPlaylistID(srv *youtube.Service, pid string, sta string, id string) error {
+	p := Playlist{}
+	p.PrivacyStatus = sta
+	// PlaylistID is deprecated in favour of PlaylistIDs
+	p.Id = pid
+	err := p.AddVideoToPlaylist(srv, id)
+	if err != nil {
+		return wrapError("adding video to playlist", err)
+	}
+	return nil
+}
+
+// AddToPlaylistIDs adds a video to playlist ids.
+func AddToPlaylistIDs(srv *youtube.Service, pids []string, sta string, id string) error {
+	p := Playlist{}
+	p.PrivacyStatus = sta
+	if len(pids) > 0 {
+		p.Title = ""
+		for _, pid := range pids {
+			p.Id = pid
+			err := p.AddVideoToPlaylist(srv, id)
+			if err != nil {
+				return wrapError("adding video to playlist", err)
+			}
+		}
+	}
+	return nil
+}
+
+// AddToPlaylistTitles adds a video to playlists by title, creating them if needed.
+func AddToPlaylistTitles(srv *youtube.Service, pnams []string, sta string, id string) error {
+	p := Playlist{}
+	if sta != "" {
+		p.PrivacyStatus = sta
+	}
+	if len(pnams) > 0 {
+		p.Id = ""
+		for _, nam := range pnams {
+			p.Title = nam
+			err := p.AddVideoToPlaylist(srv, id)
+			if err != nil {
+				return wrapError("adding video to playlist", err)
+			}
+		}
+	}
+	return nil
+}
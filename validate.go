@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Limits of YouTube metadata
+const (
+	maxTitleChars       = 100
+	maxDescriptionBytes = 5000
+	maxTagsChars        = 500
+	maxThumbnailBytes   = 2 << 20
+)
+
+// Values allowed by YouTube.
+var validPrivacyStatus = map[string]bool{"private": true, "public": true, "unlisted": true}
+var validLicense = map[string]bool{"": true, "youtube": true, "creativeCommon": true}
+var validThumbnailFormats = map[string]bool{"jpeg": true, "png": true, "gif": true, "bmp": true}
+
+// Set when run as validate command, jobs stop after validation.
+var validateOnly = false
+
+// problem is a metadata value YouTube would reject. Fixable ones are
+// truncated or removed, unless strict.
+type problem struct {
+	Field   string
+	Message string
+	Fixable bool
+}
+
+func (p problem) String() string {
+	return p.Field + ": " + p.Message
+}
+
+// validationError is all problems of a video, when upload is refused.
+type validationError struct {
+	Problems []problem
+}
+
+func (e *validationError) Error() string {
+	var txt []string
+	for _, p := range e.Problems {
+		txt = append(txt, p.String())
+	}
+	return "Invalid metadata: " + strings.Join(txt, "; ")
+}
+
+// Get length of tags as counted by YouTube, with tags having spaces
+// quoted, and separated by commas.
+func tagsLength(tags []string) int {
+	var ans = 0
+	for i, t := range tags {
+		ans += utf8.RuneCountInString(t)
+		if strings.ContainsRune(t, ' ') {
+			ans += 2
+		}
+		if i > 0 {
+			ans++
+		}
+	}
+	return ans
+}
+
+// Check a title, description pair.
+func checkText(ans []problem, field string, title string, description string) []problem {
+	if n := utf8.RuneCountInString(title); n > maxTitleChars {
+		ans = append(ans, problem{field + "title", fmt.Sprintf("%d characters, limit is %d", n, maxTitleChars), true})
+	}
+	if strings.ContainsAny(title, "<>") {
+		ans = append(ans, problem{field + "title", "contains '<' or '>'", true})
+	}
+	if !utf8.ValidString(title) {
+		ans = append(ans, problem{field + "title", "is not valid UTF-8", false})
+	}
+	if !utf8.ValidString(description) {
+		ans = append(ans, problem{field + "description", "is not valid UTF-8", false})
+	}
+	if n := len(description); n > maxDescriptionBytes {
+		ans = append(ans, problem{field + "description", fmt.Sprintf("%d bytes, limit is %d", n, maxDescriptionBytes), true})
+	}
+	if strings.ContainsAny(description, "<>") {
+		ans = append(ans, problem{field + "description", "contains '<' or '>'", true})
+	}
+	return ans
+}
+
+// Check video metadata, thumbnail and captions against YouTube limits. The
+// category of a video being updated (with current fields) is checked only if
+// changed, as valid ids may be missing from categories.
+func checkVideo(y *youtube.Video, thumbnail templateFile, captions []CaptionMeta, current map[string]string) []problem {
+	var ans []problem
+	var s, st = y.Snippet, y.Status
+	if strings.TrimSpace(s.Title) == "" {
+		ans = append(ans, problem{"title", "is empty", false})
+	}
+	ans = checkText(ans, "", s.Title, s.Description)
+	if n := tagsLength(s.Tags); n > maxTagsChars {
+		ans = append(ans, problem{"tags", fmt.Sprintf("%d characters (with quotes and commas), limit is %d", n, maxTagsChars), true})
+	}
+	for _, t := range s.Tags {
+		if strings.ContainsAny(t, "<>") {
+			ans = append(ans, problem{"tags", fmt.Sprintf("'%s' contains '<' or '>'", t), true})
+		}
+	}
+	var category = current == nil || current["snippet.categoryId"] != videoFields(y, []string{"snippet"})["snippet.categoryId"]
+	if id, err := strconv.Atoi(s.CategoryId); category && (err != nil || !isCategoryID(id)) {
+		ans = append(ans, problem{"categoryId", fmt.Sprintf("'%s' is not a category id", s.CategoryId), false})
+	}
+	for _, l := range []string{s.DefaultLanguage, s.DefaultAudioLanguage} {
+		if l != "" && !reLanguage.MatchString(l) {
+			ans = append(ans, problem{"language", fmt.Sprintf("'%s' is not a BCP-47 language code", l), false})
+		}
+	}
+	var langs []string
+	for k := range y.Localizations {
+		langs = append(langs, k)
+	}
+	sort.Strings(langs)
+	for _, k := range langs {
+		var l = y.Localizations[k]
+		if !reLanguage.MatchString(k) {
+			ans = append(ans, problem{"localizations", fmt.Sprintf("'%s' is not a BCP-47 language code", k), true})
+			continue
+		}
+		ans = checkText(ans, "localizations."+k+".", l.Title, l.Description)
+	}
+	if !validPrivacyStatus[st.PrivacyStatus] {
+		ans = append(ans, problem{"privacyStatus", fmt.Sprintf("'%s' is not private, public or unlisted", st.PrivacyStatus), false})
+	}
+	if !validLicense[st.License] {
+		ans = append(ans, problem{"license", fmt.Sprintf("'%s' is not youtube or creativeCommon", st.License), false})
+	}
+	if st.PublishAt != "" {
+		t, err := time.Parse(time.RFC3339, st.PublishAt)
+		if err != nil {
+			ans = append(ans, problem{"publishAt", fmt.Sprintf("'%s' is not a publish time", st.PublishAt), false})
+		} else if t.Before(time.Now()) {
+			ans = append(ans, problem{"publishAt", fmt.Sprintf("'%s' is in the past", st.PublishAt), false})
+		}
+	}
+	if thumbnail.Path != "" && !strings.HasPrefix(thumbnail.Path, "http") {
+		if thumbnail.Size > maxThumbnailBytes {
+			ans = append(ans, problem{"thumbnail", fmt.Sprintf("%d bytes, limit is %d", thumbnail.Size, maxThumbnailBytes), false})
+		}
+		if !validThumbnailFormats[thumbnail.Format] {
+			ans = append(ans, problem{"thumbnail", "is not a JPEG, PNG, GIF or BMP image", false})
+		}
+	}
+	for _, c := range captions {
+		if c.Language != "" && !reLanguage.MatchString(c.Language) {
+			ans = append(ans, problem{"captions", fmt.Sprintf("'%s' is not a BCP-47 language code", c.Language), false})
+		}
+	}
+	return ans
+}
+
+// Validate a video, new or being updated (with current fields). Fixable
+// problems are fixed, unless strict.
+func validateVideo(y *youtube.Video, thumbnail templateFile, captions []CaptionMeta, current map[string]string) ([]problem, error) {
+	var ans = checkVideo(y, thumbnail, captions, current)
+	for _, p := range ans {
+		if f.Strict || !p.Fixable {
+			return ans, &validationError{ans}
+		}
+	}
+	limitUploadFlags(y)
+	return ans, nil
+}
+
+// Validate videos, as set by flags, without uploading.
+func onValidate(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	validateOnly = true
+	run()
+}
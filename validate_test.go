@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// Get a video with metadata set by flags, as uploaded.
+func flagsVideo(t *testing.T) *youtube.Video {
+	var y = &youtube.Video{Snippet: &youtube.VideoSnippet{}, Status: &youtube.VideoStatus{}, RecordingDetails: &youtube.VideoRecordingDetails{}}
+	if err := getUploadFlags(y, &templateData{}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	return y
+}
+
+func TestValidatePastPublishAt(t *testing.T) {
+	// public with a past time stays public, as before publish times were parsed
+	f = appFlags{PrivacyStatus: "public", PublishAt: "2020-01-01T00:00:00Z"}
+	var y = flagsVideo(t)
+	if _, err := validateVideo(y, templateFile{}, nil, nil); err != nil {
+		t.Fatalf("validateVideo: %v", err)
+	}
+	if y.Status.PrivacyStatus != "public" || y.Status.PublishAt != "" {
+		t.Errorf("privacy, publishAt = %s, %q, want public, none", y.Status.PrivacyStatus, y.Status.PublishAt)
+	}
+	// private (default) with a past time is published now
+	f = appFlags{PublishAt: "-1h"}
+	if y = flagsVideo(t); y.Status.PrivacyStatus != "public" || y.Status.PublishAt != "" {
+		t.Errorf("privacy, publishAt = %s, %q, want public, none", y.Status.PrivacyStatus, y.Status.PublishAt)
+	}
+	f = appFlags{PrivacyStatus: "public", PublishAt: "-1h", Strict: true}
+	if _, err := validateVideo(flagsVideo(t), templateFile{}, nil, nil); err == nil {
+		t.Errorf("validateVideo with --strict should refuse a past publishAt")
+	}
+	f = appFlags{}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golangf/youtubeuploader/uploader"
+)
+
+// watchFile is the last seen state of a file.
+type watchFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// Get first existing sidecar file of a video.
+func watchSidecar(base string, exts ...string) string {
+	for _, ext := range exts {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// Caption file extensions picked up by watch.
+var watchCaptionExts = map[string]bool{".srt": true, ".vtt": true, ".sbv": true}
+
+// Get caption sidecar files of a video, as "<video>.srt" or "<video>.<lang>.srt".
+func watchCaptions(base string) []CaptionMeta {
+	var ans []CaptionMeta
+	files, _ := ioutil.ReadDir(filepath.Dir(base))
+	for _, fi := range files {
+		var pth = filepath.Join(filepath.Dir(base), fi.Name())
+		var ext = filepath.Ext(pth)
+		if fi.IsDir() || !strings.HasPrefix(pth, base+".") || !watchCaptionExts[strings.ToLower(ext)] {
+			continue
+		}
+		var lng = strings.TrimPrefix(strings.TrimSuffix(pth, ext), base)
+		if lng == "" {
+			ans = append(ans, CaptionMeta{File: pth})
+		} else if lng = lng[1:]; reLanguage.MatchString(lng) {
+			ans = append(ans, CaptionMeta{Language: lng, File: pth})
+		}
+	}
+	return ans
+}
+
+// Move a file into a subdirectory.
+func watchMove(pth string, sub string) {
+	if pth == "" {
+		return
+	}
+	var dir = filepath.Join(filepath.Dir(pth), sub)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logf("Error creating '%s': %v\n", dir, err)
+		return
+	}
+	if err := os.Rename(pth, filepath.Join(dir, filepath.Base(pth))); err != nil {
+		logf("Error moving '%s': %v\n", pth, err)
+	}
+}
+
+// Upload a video with its sidecar files, then move them to done/ or failed/.
+func watchUpload(api *apiClient, pth string) {
+	var base = strings.TrimSuffix(pth, filepath.Ext(pth))
+	job := &videoJob{
+		Video:     pth,
+		Thumbnail: watchSidecar(base, ".jpg", ".jpeg", ".png"),
+		Captions:  watchCaptions(base),
+	}
+	var meta = watchSidecar(base, ".json")
+	job.Meta = LoadVideoMeta(meta, nil)
+	printf("Uploading '%s'...\n", pth)
+	id, err := api.runJob(job)
+	if f.DryRun {
+		return
+	}
+	if outputIsJSON() {
+		writeOutput(&job.Result)
+	}
+	var sub = "done"
+	if err != nil {
+		sub = "failed"
+		printf("Failed '%s': %v\n", pth, err)
+		var errFile = filepath.Join(filepath.Dir(pth), sub, filepath.Base(pth)+".error.txt")
+		os.MkdirAll(filepath.Dir(errFile), 0755)
+		ioutil.WriteFile(errFile, []byte(err.Error()+"\n"), 0644)
+	} else {
+		printf("Uploaded '%s': %s\n", pth, id)
+	}
+	for _, p := range []string{pth, job.Thumbnail, meta} {
+		watchMove(p, sub)
+	}
+	for _, c := range job.Captions {
+		watchMove(c.File, sub)
+	}
+}
+
+// watchPool uploads videos with parallel workers, skipping videos being
+// uploaded until they are moved.
+type watchPool struct {
+	mu   sync.Mutex
+	busy map[string]bool
+	jobs chan string
+}
+
+// Create a pool of workers, with their API clients.
+func newWatchPool(api *apiClient) *watchPool {
+	var w = &watchPool{busy: map[string]bool{}, jobs: make(chan string)}
+	for _, a := range workerClients(api, getParallel(0)) {
+		go func(a *apiClient) {
+			for pth := range w.jobs {
+				watchUpload(a, pth)
+				w.mu.Lock()
+				delete(w.busy, pth)
+				w.mu.Unlock()
+			}
+		}(a)
+	}
+	return w
+}
+
+// Upload a video with the next free worker, unless being uploaded.
+func (w *watchPool) upload(pth string) {
+	w.mu.Lock()
+	if w.busy[pth] {
+		w.mu.Unlock()
+		return
+	}
+	w.busy[pth] = true
+	w.mu.Unlock()
+	w.jobs <- pth
+}
+
+// Check if a video is being uploaded.
+func (w *watchPool) uploading(pth string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.busy[pth]
+}
+
+// Scan a directory, and upload videos that have stopped growing.
+func watchScan(w *watchPool, dir string, seen map[string]watchFile) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		logf("Error reading '%s': %v\n", dir, err)
+		return
+	}
+	var found = map[string]bool{}
+	for _, fi := range files {
+		if fi.IsDir() || !videoExts[strings.ToLower(filepath.Ext(fi.Name()))] {
+			continue
+		}
+		var pth = filepath.Join(dir, fi.Name())
+		var now = watchFile{fi.Size(), fi.ModTime()}
+		if w != nil && w.uploading(pth) {
+			continue
+		}
+		found[pth] = true
+		if old, ok := seen[pth]; !ok || old != now || now.size == 0 {
+			seen[pth] = now
+			continue
+		}
+		delete(seen, pth)
+		if w == nil {
+			watchUpload(nil, pth)
+		} else {
+			w.upload(pth)
+		}
+	}
+	for pth := range seen {
+		if !found[pth] {
+			delete(seen, pth)
+		}
+	}
+}
+
+// Watch a directory for new videos, and upload them.
+func onWatch(args []string) {
+	os.Args = append(os.Args[:1], args...)
+	getFlags()
+	uploader.Logf = logf
+	var dir = flag.Arg(0)
+	if dir == "" {
+		printf("No directory to watch!\n")
+		os.Exit(1)
+	}
+	var interval = parseDuration(f.WatchInterval, 10*time.Second)
+	// dry run scans twice, and keeps files in place
+	if f.DryRun {
+		var seen = map[string]watchFile{}
+		watchScan(nil, dir, seen)
+		watchScan(nil, dir, seen)
+		return
+	}
+	w := newWatchPool(getAPIClient())
+	startControl()
+	printf("Watching '%s' every %v (%d at once)...\n", dir, interval, getParallel(0))
+	var seen = map[string]watchFile{}
+	for {
+		watchScan(w, dir, seen)
+		time.Sleep(interval)
+	}
+}
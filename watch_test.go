@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatchCaptions(t *testing.T) {
+	var dir = t.TempDir()
+	for _, nam := range []string{"ep.srt", "ep.en.vtt", "ep.pt-BR.SRT", "ep.notalang!.srt", "ep.fr.txt", "ep2.srt", "other.srt"} {
+		writeTestFile(t, dir, nam, []byte("1"))
+	}
+	var base = filepath.Join(dir, "ep")
+	var want = []CaptionMeta{
+		{Language: "en", File: base + ".en.vtt"},
+		{Language: "pt-BR", File: base + ".pt-BR.SRT"},
+		{File: base + ".srt"},
+	}
+	if got := watchCaptions(base); !reflect.DeepEqual(got, want) {
+		t.Errorf("watchCaptions = %+v, want %+v", got, want)
+	}
+}
+
+// Wait for a file to exist.
+func waitFile(t *testing.T, pth string) {
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(pth); err == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("'%s' not found", pth)
+}
+
+func TestWatchScan(t *testing.T) {
+	fake, api, _ := newTestAPI(t)
+	var dir = t.TempDir()
+	writeTestFile(t, dir, "ep.mp4", []byte("video"))
+	writeTestFile(t, dir, "ep.json", []byte(`{"title": "Episode"}`))
+	writeTestFile(t, dir, "ep.jpg", []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00\xff\xd9"))
+	writeTestFile(t, dir, "ep.en.srt", []byte("1\n00:00:00,000 --> 00:00:01,000\nHi\n"))
+	writeTestFile(t, dir, "bad.mp4", []byte("bad video"))
+	writeTestFile(t, dir, "bad.json", []byte(`{"categoryId": "999"}`))
+	writeTestFile(t, dir, "growing.mp4", []byte("vid"))
+	writeTestFile(t, dir, "empty.mp4", nil)
+	writeTestFile(t, dir, "notes.txt", []byte("text"))
+	var w = newWatchPool(api)
+	var seen = map[string]watchFile{}
+	watchScan(w, dir, seen)
+	if len(seen) != 4 || len(fake.Videos()) != 0 {
+		t.Fatalf("first scan: seen %d, %d videos, want 4, none uploaded", len(seen), len(fake.Videos()))
+	}
+	// videos that stopped growing are uploaded
+	writeTestFile(t, dir, "growing.mp4", []byte("video"))
+	watchScan(w, dir, seen)
+	// captions and meta are moved last
+	waitFile(t, filepath.Join(dir, "done", "ep.en.srt"))
+	waitFile(t, filepath.Join(dir, "failed", "bad.json"))
+	// workers are done before flags are reset by other tests
+	for i := 0; i < 100 && (w.uploading(filepath.Join(dir, "ep.mp4")) || w.uploading(filepath.Join(dir, "bad.mp4"))); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var vs = fake.Videos()
+	if len(vs) != 1 || vs[0].Snippet.Title != "Episode" {
+		t.Fatalf("videos = %v, want Episode", vs)
+	}
+	if len(fake.Thumbnail(vs[0].Id)) == 0 || len(fake.Captions(vs[0].Id)) != 1 {
+		t.Errorf("thumbnail, captions = %d bytes, %v", len(fake.Thumbnail(vs[0].Id)), fake.Captions(vs[0].Id))
+	}
+	for _, nam := range []string{"done/ep.mp4", "done/ep.json", "done/ep.jpg", "failed/bad.mp4", "failed/bad.mp4.error.txt", "growing.mp4", "empty.mp4", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, nam)); err != nil {
+			t.Errorf("'%s' not found", nam)
+		}
+	}
+	if _, ok := seen[filepath.Join(dir, "growing.mp4")]; !ok {
+		t.Errorf("growing.mp4 no longer seen")
+	}
+}
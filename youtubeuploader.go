@@ -4,91 +4,136 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
-	"golang.org/x/oauth2"
+	"github.com/golangf/youtubeuploader/uploader"
 	"google.golang.org/api/youtube/v3"
 )
 
 // Global variables
 var appVersion = ""
 
+// Commands, run as "youtubeuploader <command> [options]".
+var commands = map[string]func(args []string){
+	"ctl":        onCtl,
+	"export":     onExport,
+	"history":    onHistory,
+	"list":       onList,
+	"reschedule": onReschedule,
+	"serve-fake": onServeFake,
+	"update":     onUpdate,
+	"validate":   onValidate,
+	"watch":      onWatch,
+}
+
 func onTitle(srv *youtube.Service, txt string) {
-	for id := range searchVideoTitle(srv, txt) {
+	ids, err := uploader.FindVideoTitle(srv, txt)
+	if outputIsJSON() {
+		writeOutput(&searchResult{Event: "search", Title: txt, Ids: ids, Error: newOutputError(err)})
+		os.Exit(exitCode(err))
+	}
+	if err != nil {
+		fatal(err)
+	}
+	for _, id := range ids {
 		fmt.Printf("%v\n", id)
 	}
 }
 
-// Main.
-func main() {
-	getFlags()
-	// on help
-	if f.Help {
-		fmt.Printf("Upload YouTube videos with caption through machines.\n\n")
-		flag.PrintDefaults()
-		os.Exit(1)
+func openJobFile(nam string) (io.ReadCloser, int64, error) {
+	if nam == "" {
+		return nil, 0, nil
 	}
-	// on version
-	if f.Version {
-		fmt.Printf("youtubeuploader v%s\n", appVersion)
-		os.Exit(0)
+	return uploader.Open(nam)
+}
+
+// Upload (or update) a video, with its thumbnail, captions and playlists.
+func runJob(api *apiClient, job *videoJob) (string, error) {
+	var id = job.Id
+	var res = &job.Result
+	res.Event = "result"
+	res.File = parseString(job.Video, res.File)
+	res.Captions, res.Playlists, res.Warnings = nil, nil, nil
+	if res.Started.IsZero() {
+		res.Started = time.Now()
 	}
-	if f.Video == "" && f.Title == "" {
-		fmt.Printf("No video file to upload!\n")
-		os.Exit(1)
+	// skip upload of a video uploaded before, by content hash
+	var err error
+	if id == "" && !validateOnly {
+		if res.Hash, err = videoHash(job.Video); err != nil {
+			return id, err
+		}
+		if res.Hash != "" && !f.Reupload {
+			defer lockUpload(res.Hash)()
+			if id, err = findUpload(api, res.Hash, job.Video); err != nil {
+				return id, err
+			}
+			if id != "" {
+				printf("'%s' already uploaded as %s, skipping upload\n", job.Video, id)
+				job.Id, job.Existing = id, true
+			}
+		}
+	}
+	var videoFile io.ReadCloser
+	var fileSize int64
+	if !job.Existing {
+		if videoFile, fileSize, err = openJobFile(job.Video); err != nil {
+			return id, err
+		}
 	}
-
-	var id = f.Id
-	uploadTime := getUploadTime()
-	videoFile, fileSize := openFile(f.Video)
-	thumbnailFile, _ := openFile(f.Thumbnail)
-	captionFile, _ := openFile(f.Caption)
 	if videoFile != nil {
 		defer videoFile.Close()
 	}
+	thumbnailFile, thumbnailSize, err := openJobFile(job.Thumbnail)
+	if err != nil {
+		return id, err
+	}
 	if thumbnailFile != nil {
 		defer thumbnailFile.Close()
 	}
-	if captionFile != nil {
-		defer captionFile.Close()
+	var captions []CaptionMeta
+	for _, c := range append(append([]CaptionMeta{}, job.Captions...), job.Meta.Captions...) {
+		// exported tracks have no file, until one is given
+		if c.File == "" {
+			logf("Skipping caption %v:%v without file\n", c.Language, c.Name)
+			continue
+		}
+		captions = append(captions, c)
 	}
-
-	ctx := context.Background()
-	transport := &limitTransport{rt: http.DefaultTransport, lr: uploadTime, filesize: fileSize}
-	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{
-		Transport: transport,
-	})
-
-	var quitChan chanChan
-	if f.Log {
-		quitChan = make(chanChan)
-		go func() {
-			Progress(quitChan, transport, fileSize)
-		}()
-	}
-	client, err := buildOAuthHTTPClient(ctx, []string{youtube.YoutubeUploadScope, youtube.YoutubepartnerScope, youtube.YoutubeScope})
-	if err != nil {
-		log.Fatalf("Error building OAuth client: %v", err)
+	var captionFiles []io.ReadCloser
+	var captionSizes []int64
+	for _, c := range captions {
+		fil, siz, err := uploader.Open(c.File)
+		if err != nil {
+			return id, err
+		}
+		defer fil.Close()
+		captionFiles = append(captionFiles, fil)
+		captionSizes = append(captionSizes, siz)
 	}
+
 	upload := &youtube.Video{
 		Snippet:          &youtube.VideoSnippet{},
 		RecordingDetails: &youtube.VideoRecordingDetails{},
 		Status:           &youtube.VideoStatus{},
 	}
-	videoMeta := LoadVideoMeta(f.Meta, upload)
-	service, err := youtube.New(client)
-	if err != nil {
-		log.Fatalf("Error creating YouTube client: %s", err)
-	}
-	// show video id
-	if f.Video == "" && f.Id == "" && f.Title != "" {
-		for _, id := range searchVideoTitle(service, f.Title) {
-			fmt.Printf("%v\n", id)
+	videoMeta := &job.Meta
+	// update merges into the current video, refused if changed meanwhile
+	var current map[string]string
+	if id != "" && videoFile == nil && !(f.DryRun && job.Existing) {
+		api = readAPIClient(api)
+		logf("Fetching video %v...\n", id)
+		if upload, err = uploader.GetVideo(api.service, id, updateParts); err != nil {
+			return id, err
 		}
-		os.Exit(0)
+		current = videoFields(upload, updateParts)
+		patchVideo(upload, videoMeta)
+	} else if videoMeta.JSON != nil {
+		ApplyVideoMeta(videoMeta, upload)
 	}
 	if f.PlaylistIds != "" && len(videoMeta.PlaylistIDs) == 0 {
 		videoMeta.PlaylistIDs = strings.Split(f.PlaylistIds, ";")
@@ -98,45 +143,247 @@ func main() {
 	}
 	// update upload
 	if id != "" || videoFile != nil {
-		getUploadFlags(upload, &videoMeta)
+		var tmpl = getTemplateData(videoMeta, job.Video, job.Thumbnail)
+		if videoFile != nil {
+			for _, w := range probeWarnings(tmpl.File) {
+				printf("Warning: '%s': %s\n", job.Video, w)
+				res.Warnings = append(res.Warnings, w)
+			}
+		}
+		if current != nil {
+			err = mergeUploadFlags(upload, tmpl)
+		} else {
+			err = getUploadFlags(upload, tmpl, job.Video)
+		}
+		if err != nil {
+			return id, err
+		}
+		if videoFile != nil {
+			applyMediaInfo(upload, tmpl.File.MediaInfo)
+		}
+		if videoFile != nil && res.Hash != "" && f.HashTag {
+			upload.Snippet.Tags = append(upload.Snippet.Tags, hashTag(res.Hash))
+		}
+		problems, err := validateVideo(upload, tmpl.Thumbnail, captions, current)
+		if err != nil {
+			return id, err
+		}
+		for _, p := range problems {
+			printf("Warning: %s (fixed)\n", p)
+			res.Warnings = append(res.Warnings, p.String())
+		}
 		logUploadFlags(upload)
+		logMediaInfo(tmpl.File.MediaInfo)
+		res.Metadata = upload
+		if current != nil {
+			res.Changes = diffFields(current, videoFields(upload, updateParts))
+			printChanges(id, res.Changes)
+		}
 	}
+	if validateOnly {
+		printf("Valid '%s'\n", parseString(job.Video, job.Id))
+		res.Action = "validated"
+		return id, nil
+	}
+	if f.DryRun {
+		return id, printDryRun(dryRunRequests(job, upload, videoMeta, captions, fileSize))
+	}
+	var service = api.service
+	var transport = api.transport
 	// upload video
 	if videoFile != nil {
-		logf("Uploading file '%s'...\n", f.Video)
-		video := uploadVideo(service, videoFile, upload, parseInt(f.UploadChunk, 0), quitChan)
+		var ctx = transport.reset(api.ctx, fileSize, job.Priority)
+		uploadProgress.start(transport, job.Video, f.Log || f.Output == outputJSONL)
+		logf("Uploading file '%s'...\n", job.Video)
+		var start = time.Now()
+		var video *youtube.Video
+		if fileSize > 0 {
+			video, err = uploader.UploadVideoResumable(ctx, api.http, service, job.Video, videoFile, fileSize, upload, parseInt(f.UploadChunk, 0), f.Resume)
+		} else {
+			video, err = uploader.UploadVideo(service, videoFile, upload, parseInt(f.UploadChunk, 0))
+		}
+		uploadProgress.stop(transport)
+		res.UploadTime = time.Since(start).Seconds()
+		var h = &historyRecord{Op: historyUpload, Started: start, Credential: api.clientID, File: job.Video, Hash: res.Hash, Bytes: transport.done()}
+		if video != nil {
+			h.Id = video.Id
+		}
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
 		logf("Upload successful! Video ID: %v\n", video.Id)
 		id = video.Id
-	} else if id != "" {
+		addUpload(res.Hash, id, job.Video)
+		res.Action = "uploaded"
+	} else if parts := changedParts(res.Changes); id != "" && len(parts) > 0 {
 		logf("Updating video %v...\n", id)
-		updateVideo(service, id, upload)
+		var h = &historyRecord{Op: historyUpdate, Started: time.Now(), Credential: api.clientID, File: res.File, Hash: res.Hash, Id: id}
+		_, err = uploader.UpdateVideoIfUnchanged(service, upload, updateParts, parts)
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
 		logf("Update successful!\n")
+		if res.Action == "" {
+			res.Action = "updated"
+		}
+	} else if id != "" && res.Action == "" {
+		res.Action = "unchanged"
 	}
 	// upload thumbnail
 	if id != "" && thumbnailFile != nil {
-		logf("Uploading thumbnail %v '%s'...\n", id, f.Thumbnail)
-		uploadThumbnail(service, id, thumbnailFile)
+		logf("Uploading thumbnail %v '%s'...\n", id, job.Thumbnail)
+		var h = &historyRecord{Op: historyThumbnail, Started: time.Now(), Credential: api.clientID, File: job.Thumbnail, Id: id, Bytes: thumbnailSize}
+		err = uploader.UploadThumbnail(service, id, thumbnailFile)
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
 		logf("Thumbnail uploaded!\n")
+		res.Thumbnail = job.Thumbnail
 	}
-	// upload caption
-	if id != "" && captionFile != nil {
-		logf("Uploading caption %v:%v '%s'...\n", id, upload.Snippet.DefaultLanguage, f.Caption)
-		uploadCaption(service, id, upload.Snippet.DefaultLanguage, captionFile)
-		logf("Caption uploaded!\n")
+	// upload captions
+	for i, c := range captions {
+		if id == "" {
+			break
+		}
+		var t = captionTrack(c, upload.Snippet.DefaultLanguage)
+		logf("Uploading caption %v:%v '%s'...\n", id, t.Language, c.File)
+		var h = &historyRecord{Op: historyCaption, Started: time.Now(), Credential: api.clientID, File: c.File, Id: id, Bytes: captionSizes[i]}
+		updated, err := uploader.UploadCaptionTrack(service, id, t, captionFiles[i])
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
+		var r = captionResult{File: c.File, Language: t.Language, Name: t.Name, Action: "uploaded"}
+		if updated {
+			r.Action = "updated"
+		}
+		logf("Caption %s!\n", r.Action)
+		res.Captions = append(res.Captions, r)
 	}
 	// add to playlist id
 	if id != "" && videoMeta.PlaylistID != "" {
 		logf("Adding to playlist id %v->[%v]...\n", id, 1)
-		addToPlaylistID(service, videoMeta.PlaylistID, upload.Status.PrivacyStatus, id)
+		var h = &historyRecord{Op: historyPlaylist, Started: time.Now(), Credential: api.clientID, File: res.File, Id: id}
+		err = uploader.AddToPlaylistID(service, videoMeta.PlaylistID, upload.Status.PrivacyStatus, id)
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
+		res.Playlists = append(res.Playlists, playlistResult{Id: videoMeta.PlaylistID})
 	}
 	// add to playlist ids
 	if id != "" && videoMeta.PlaylistIDs != nil {
 		logf("Adding to playlist ids %v->[%v]...\n", id, len(videoMeta.PlaylistIDs))
-		addToPlaylistIDs(service, videoMeta.PlaylistIDs, upload.Status.PrivacyStatus, id)
+		var h = &historyRecord{Op: historyPlaylist, Started: time.Now(), Credential: api.clientID, File: res.File, Id: id}
+		err = uploader.AddToPlaylistIDs(service, videoMeta.PlaylistIDs, upload.Status.PrivacyStatus, id)
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
+		for _, pid := range videoMeta.PlaylistIDs {
+			res.Playlists = append(res.Playlists, playlistResult{Id: pid})
+		}
 	}
 	// add to playlist titles
 	if id != "" && videoMeta.PlaylistTitles != nil {
 		logf("Adding to playlist ids %v->[%v]...\n", id, len(videoMeta.PlaylistTitles))
-		addToPlaylistTitles(service, videoMeta.PlaylistTitles, upload.Status.PrivacyStatus, id)
+		var h = &historyRecord{Op: historyPlaylist, Started: time.Now(), Credential: api.clientID, File: res.File, Id: id}
+		err = uploader.AddToPlaylistTitles(service, videoMeta.PlaylistTitles, upload.Status.PrivacyStatus, id)
+		addHistory(h, err)
+		if err != nil {
+			return id, err
+		}
+		for _, title := range videoMeta.PlaylistTitles {
+			res.Playlists = append(res.Playlists, playlistResult{Title: title})
+		}
+	}
+	return id, nil
+}
+
+// Create API client, as set by flags.
+func getAPIClient() *apiClient {
+	transport := &limitTransport{rt: http.DefaultTransport, bw: getBandwidth()}
+	return newAPIClient(context.Background(), transport)
+}
+
+// API client of dry run and validate, to fetch videos being updated.
+var readAPI *apiClient
+
+// Get API client, or create one to read videos if none.
+func readAPIClient(api *apiClient) *apiClient {
+	if api != nil {
+		return api
+	}
+	if readAPI == nil {
+		readAPI = getAPIClient()
+	}
+	return readAPI
+}
+
+// Main.
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+	run()
+}
+
+// Upload (or update) videos, as set by flags.
+func run() {
+	getFlags()
+	uploader.Logf = logf
+	// on help
+	if f.Help {
+		fmt.Printf("Upload YouTube videos with caption through machines.\n\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	// on version
+	if f.Version {
+		fmt.Printf("youtubeuploader v%s\n", appVersion)
+		os.Exit(0)
+	}
+	if f.Video == "" && f.Id == "" && f.Title == "" && f.Batch == "" {
+		fmt.Printf("No video file to upload!\n")
+		os.Exit(1)
+	}
+
+	// dry run and validate need no API client
+	var api *apiClient
+	if !f.DryRun && !validateOnly {
+		api = getAPIClient()
+	}
+	startControl()
+	// upload batch
+	if f.Batch != "" {
+		os.Exit(runBatch(api, f.Batch))
+	}
+	// show video id
+	if f.Video == "" && f.Id == "" && f.Title != "" && !validateOnly {
+		if f.DryRun {
+			var reqs = dryRunList()
+			reqs[2].Parts = []string{"snippet"}
+			printDryRun(reqs)
+			os.Exit(0)
+		}
+		onTitle(api.service, f.Title)
+		os.Exit(0)
+	}
+
+	job := &videoJob{Id: f.Id, Video: f.Video, Thumbnail: f.Thumbnail, Captions: parseCaptions(f.Caption, "")}
+	job.Meta = LoadVideoMeta(f.Meta, nil)
+	_, err := api.runJob(job)
+	if outputIsJSON() && !f.DryRun {
+		writeOutput(&job.Result)
+		os.Exit(exitCode(err))
+	}
+	if err != nil {
+		fatal(err)
 	}
 }
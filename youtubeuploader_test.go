@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golangf/youtubeuploader/fakeyt"
+)
+
+// Create an API client of a fake server, with credentials and ledgers in a
+// temporary directory.
+func newTestAPI(t *testing.T) (*fakeyt.Server, *apiClient, string) {
+	var fake = fakeyt.New()
+	var srv = httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	var dir = t.TempDir()
+	writeTestFile(t, dir, "client_id.json", []byte(`{"installed":{"client_id":"x","client_secret":"y","redirect_uris":["http://localhost"]}}`))
+	writeTestFile(t, dir, "client_token.json", []byte(`{"access_token":"a","token_type":"Bearer","refresh_token":"r","expiry":"2099-01-01T00:00:00Z"}`))
+	f = appFlags{
+		ClientID:    filepath.Join(dir, "client_id.json"),
+		ClientToken: filepath.Join(dir, "client_token.json"),
+		ApiEndpoint: srv.URL,
+	}
+	getFlagsDynamic()
+	quotaLedger, uploadLedger, readAPI = nil, nil, nil
+	return fake, getAPIClient(), dir
+}
+
+func writeTestFile(t *testing.T, dir, nam string, dat []byte) string {
+	var pth = filepath.Join(dir, nam)
+	if err := ioutil.WriteFile(pth, dat, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return pth
+}
+
+// Get what a function writes to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout = os.Stdout
+	os.Stdout = w
+	var out = make(chan []byte)
+	go func() {
+		dat, _ := ioutil.ReadAll(r)
+		out <- dat
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	w.Close()
+	return string(<-out)
+}
+
+func TestRunJob(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var media = bytes.Repeat([]byte("video"), 100000)
+	var thumb = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00\xff\xd9")
+	var job = &videoJob{
+		Video:     writeTestFile(t, dir, "ep01.mp4", media),
+		Thumbnail: writeTestFile(t, dir, "ep01.jpg", thumb),
+		Captions:  []CaptionMeta{{Language: "en", File: writeTestFile(t, dir, "ep01.srt", []byte("1\n00:00:00,000 --> 00:00:01,000\nHello\n"))}},
+	}
+	job.Meta.PlaylistTitles = []string{"Episodes"}
+	f.Title = "{{.File.Base}}"
+	id, err := api.runJob(job)
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	v, size := fake.Video(id)
+	if v == nil || size != int64(len(media)) {
+		t.Fatalf("video %s: %v, %d bytes, want %d", id, v, size, len(media))
+	}
+	if v.Snippet.Title != "ep01" {
+		t.Errorf("title = %q, want %q", v.Snippet.Title, "ep01")
+	}
+	if !bytes.Equal(fake.Thumbnail(id), thumb) {
+		t.Errorf("thumbnail = %q, want %q", fake.Thumbnail(id), thumb)
+	}
+	if c := fake.Captions(id); len(c) != 1 || c[0].Snippet.Language != "en" {
+		t.Errorf("captions = %v, want one en track", c)
+	}
+	var pl = fake.Playlists()
+	if len(pl) != 1 || pl[0].Snippet.Title != "Episodes" {
+		t.Fatalf("playlists = %v, want Episodes", pl)
+	}
+	if items := fake.PlaylistItems(pl[0].Id); len(items) != 1 || items[0] != id {
+		t.Errorf("playlist items = %v, want [%s]", items, id)
+	}
+	if job.Result.Action != "uploaded" {
+		t.Errorf("action = %q, want uploaded", job.Result.Action)
+	}
+}
+
+func TestRunJobUpdate(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var job = &videoJob{Video: writeTestFile(t, dir, "ep02.mp4", []byte("video"))}
+	f.Title = "old"
+	id, err := api.runJob(job)
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	// only snippet changes, fetched with all parts
+	f.Title = "new"
+	job = &videoJob{Id: id}
+	if _, err = api.runJob(job); err != nil {
+		t.Fatalf("runJob update: %v", err)
+	}
+	if v, _ := fake.Video(id); v.Snippet.Title != "new" || job.Result.Action != "updated" {
+		t.Errorf("title, action = %q, %q, want new, updated", v.Snippet.Title, job.Result.Action)
+	}
+}
+
+func TestRunJobDedupe(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var video = writeTestFile(t, dir, "ep03.mp4", []byte("video"))
+	f.Title = "{{.File.Base}}"
+	f.Description = "From {{.File.Name}}"
+	id, err := api.runJob(&videoJob{Video: video})
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	// run again, found in ledger, with same templates
+	var job = &videoJob{Video: video}
+	again, err := api.runJob(job)
+	if err != nil {
+		t.Fatalf("runJob again: %v", err)
+	}
+	if again != id || len(fake.Videos()) != 1 {
+		t.Fatalf("uploaded again as %s, %d videos", again, len(fake.Videos()))
+	}
+	v, _ := fake.Video(id)
+	if v.Snippet.Title != "ep03" || v.Snippet.Description != "From ep03.mp4" {
+		t.Errorf("title, description = %q, %q, want ep03, From ep03.mp4", v.Snippet.Title, v.Snippet.Description)
+	}
+	if job.Result.Action != "unchanged" || job.Result.File != video {
+		t.Errorf("action, file = %q, %q, want unchanged, %s", job.Result.Action, job.Result.File, video)
+	}
+	// dry run reports the ledger hit, without connecting
+	f.DryRun = true
+	readAPI = nil
+	if again, err = runJob(nil, &videoJob{Video: video}); err != nil || again != id {
+		t.Errorf("dry run = %s, %v, want %s", again, err, id)
+	}
+	if readAPI != nil {
+		t.Errorf("dry run connected to API")
+	}
+}
+
+func TestRunJobParallelDedupe(t *testing.T) {
+	fake, api, dir := newTestAPI(t)
+	var media = bytes.Repeat([]byte("video"), 100000)
+	var jobs = []*videoJob{
+		{Video: writeTestFile(t, dir, "a.mp4", media)},
+		{Video: writeTestFile(t, dir, "b.mp4", media)},
+	}
+	f.Parallel = "2"
+	runWorkers(api, len(jobs), func(a *apiClient, i int) {
+		a.runJob(jobs[i])
+	})
+	if n := len(fake.Videos()); n != 1 {
+		t.Fatalf("identical files uploaded as %d videos", n)
+	}
+	var actions = jobs[0].Result.Action + "," + jobs[1].Result.Action
+	if jobs[0].Result.Id != jobs[1].Result.Id || (actions != "uploaded,unchanged" && actions != "unchanged,uploaded") {
+		t.Errorf("results = %s %s, %s %s", jobs[0].Result.Id, jobs[0].Result.Action, jobs[1].Result.Id, jobs[1].Result.Action)
+	}
+}